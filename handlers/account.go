@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/export"
+	"github.com/leonardoklaser/Chirpy/internal/jobs"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	exportTTL         = 7 * 24 * time.Hour
+	exportLinkTTL     = 15 * time.Minute
+	accountPurgeBatch   = 100
+	exportStatusPending = "pending"
+	exportStatusReady   = "ready"
+)
+
+// DeleteAccount schedules the caller's account for deletion. Until the grace
+// period ends the account is locked out of the API, and logging in again
+// restores it.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		CurrentPassword string `json:"current_password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, ok := confirmCurrentPassword(w, r, cfg, params.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	deletion, err := cfg.DB.ScheduleAccountDeletion(r.Context(), database.ScheduleAccountDeletionParams{
+		UserID:     user.ID,
+		PurgeAfter: time.Now().Add(cfg.AccountDeletionGrace),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to delete account: %v", err))
+		return
+	}
+	if err := cfg.DB.RevokeAllRefreshTokensForUser(r.Context(), user.ID); err != nil {
+		log.Printf("Error revoking sessions for user %s: %v", user.ID, err)
+	}
+
+	recordAudit(r, cfg, audit.ActionDeletionRequested, uuid.NullUUID{UUID: user.ID, Valid: true}, "user", user.ID.String(), map[string]any{"purge_after": deletion.PurgeAfter})
+
+	utils.RespondWithJson(w, http.StatusAccepted, models.AccountDeletion{RequestedAt: deletion.RequestedAt, PurgeAfter: deletion.PurgeAfter})
+}
+
+// restoreAccount cancels a pending deletion after a successful login.
+func restoreAccount(r *http.Request, cfg *config.ApiConfig, userID uuid.UUID) {
+	result, err := cfg.DB.CancelAccountDeletion(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error cancelling deletion of user %s: %v", userID, err)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		recordAudit(r, cfg, audit.ActionDeletionCancelled, uuid.NullUUID{UUID: userID, Valid: true}, "user", userID.String(), nil)
+	}
+}
+
+// PurgeDeletedAccounts removes accounts whose grace period is over, along
+// with their export files and media blobs, and deletes expired exports. Rows in other tables
+// go with the user through ON DELETE CASCADE.
+func PurgeDeletedAccounts(ctx context.Context, cfg *config.ApiConfig) error {
+	due, err := cfg.DB.ListDueAccountDeletions(ctx, accountPurgeBatch)
+	if err != nil {
+		return fmt.Errorf("error listing accounts to purge: %w", err)
+	}
+	for _, deletion := range due {
+		files, err := cfg.DB.ListDataExportFilesForUser(ctx, deletion.UserID)
+		if err != nil {
+			return fmt.Errorf("error listing exports of user %s: %w", deletion.UserID, err)
+		}
+		for _, file := range files {
+			removeExportFile(file)
+		}
+		attachments, err := cfg.DB.ListMediaForUser(ctx, deletion.UserID)
+		if err != nil {
+			return fmt.Errorf("error listing media of user %s: %w", deletion.UserID, err)
+		}
+		for _, attachment := range attachments {
+			deleteMediaBlobs(ctx, cfg, attachment)
+		}
+
+		_, err = cfg.DB.DeleteUserById(ctx, deletion.UserID)
+		if err != nil {
+			return fmt.Errorf("error purging user %s: %w", deletion.UserID, err)
+		}
+		_, err = cfg.Audit.Record(ctx, audit.Event{
+			Action:     audit.ActionUserPurged,
+			TargetType: "user",
+			TargetID:   deletion.UserID.String(),
+			Details:    map[string]any{"requested_at": deletion.RequestedAt},
+		})
+		if err != nil {
+			log.Printf("Error recording audit event %s: %v", audit.ActionUserPurged, err)
+		}
+	}
+
+	expired, err := cfg.DB.ListExpiredDataExports(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing expired exports: %w", err)
+	}
+	for _, dataExport := range expired {
+		removeExportFile(dataExport.FilePath)
+		if err := cfg.DB.DeleteDataExport(ctx, dataExport.ID); err != nil {
+			return fmt.Errorf("error deleting export %s: %w", dataExport.ID, err)
+		}
+	}
+	return nil
+}
+
+func removeExportFile(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error removing export file %s: %v", path, err)
+	}
+}
+
+func exportDownloadPath(id uuid.UUID) string {
+	return fmt.Sprintf("/api/exports/%s/download", id)
+}
+
+func toDataExport(cfg *config.ApiConfig, dataExport database.DataExport) models.DataExport {
+	result := models.DataExport{
+		ID:          dataExport.ID,
+		Status:      dataExport.Status,
+		CreatedAt:   dataExport.CreatedAt,
+		CompletedAt: nullTimePtr(dataExport.CompletedAt),
+		ExpiresAt:   nullTimePtr(dataExport.ExpiresAt),
+	}
+	if dataExport.Status == exportStatusReady {
+		path := exportDownloadPath(dataExport.ID)
+		query := auth.SignURLPath(path, time.Now().Add(exportLinkTTL), cfg.SecretKey)
+		result.DownloadURL = fmt.Sprintf("%s%s?%s", cfg.BaseURL, path, query.Encode())
+	}
+	return result
+}
+
+func RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create export: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	dataExport, err := qtx.CreateDataExport(r.Context(), uuidUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusConflict, "An export is already being prepared")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create export: %v", err))
+		return
+	}
+
+	// The archive is built by a job; the client polls GetDataExport until it
+	// is ready. Enqueueing in the same transaction means a pending export
+	// always has a job that will finish it.
+	_, err = jobs.NewClient(jobs.NewPostgresStore(qtx)).Enqueue(r.Context(), JobBuildDataExport, dataExportJob{
+		ExportID: dataExport.ID,
+		UserID:   uuidUser,
+	}, jobs.Unique(dataExport.ID.String()))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create export: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create export: %v", err))
+		return
+	}
+
+	recordAudit(r, cfg, audit.ActionDataExported, uuid.NullUUID{UUID: uuidUser, Valid: true}, "user", uuidUser.String(), map[string]any{"export_id": dataExport.ID})
+
+	utils.RespondWithJson(w, http.StatusAccepted, toDataExport(cfg, dataExport))
+}
+
+// dataExportJob is the payload of a JobBuildDataExport job.
+type dataExportJob struct {
+	ExportID uuid.UUID `json:"export_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+// buildDataExport writes the archive of a pending export. An export that is
+// no longer pending was already finished by an earlier attempt. A failed
+// build marks the export as failed so the user can request a new one; an
+// error completing it is returned so the job is retried.
+func buildDataExport(ctx context.Context, cfg *config.ApiConfig, job dataExportJob) error {
+	dataExport, err := cfg.DB.GetDataExport(ctx, job.ExportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if dataExport.Status != exportStatusPending {
+		return nil
+	}
+
+	path, err := writeDataExport(ctx, cfg, job.ExportID, job.UserID)
+	if err != nil {
+		log.Printf("Error building export %s: %v", job.ExportID, err)
+		removeExportFile(path)
+		return cfg.DB.FailDataExport(ctx, database.FailDataExportParams{ID: job.ExportID, Error: err.Error()})
+	}
+
+	err = cfg.DB.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		ID:        job.ExportID,
+		FilePath:  path,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(exportTTL), Valid: true},
+	})
+	if err != nil {
+		removeExportFile(path)
+		return fmt.Errorf("error completing export %s: %w", job.ExportID, err)
+	}
+	return nil
+}
+
+// collectExportFiles gathers everything stored about userID. Secrets such as
+// password hashes and token values are left out.
+func collectExportFiles(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) ([]export.File, error) {
+	user, err := cfg.DB.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+	profile := models.User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		ChirpyRed:     chirpyRed(ctx, cfg, user.ID),
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		Location:      user.Location,
+		Website:       user.Website,
+		AvatarURL:     user.AvatarUrl,
+	}
+
+	chirpRows, err := cfg.DB.GetChirpsByUserId(ctx, database.GetChirpsByUserIdParams{UserID: userID, ViewerID: uuid.NullUUID{UUID: userID, Valid: true}})
+	if err != nil {
+		return nil, fmt.Errorf("error loading chirps: %w", err)
+	}
+	chirps := []models.Chirp{}
+	for _, val := range chirpRows {
+		chirps = append(chirps, toChirp(val.Chirp))
+	}
+	if err := decorateChirps(ctx, cfg, uuid.NullUUID{UUID: userID, Valid: true}, chirps); err != nil {
+		return nil, fmt.Errorf("error loading chirp details: %w", err)
+	}
+
+	likeRows, err := cfg.DB.ListLikesForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading likes: %w", err)
+	}
+	likes := []models.Like{}
+	for _, val := range likeRows {
+		likes = append(likes, models.Like{ChirpID: val.ChirpID, CreatedAt: val.CreatedAt})
+	}
+
+	followRows, err := cfg.DB.ListFollowsForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading follows: %w", err)
+	}
+	follows := []models.Follow{}
+	for _, val := range followRows {
+		follows = append(follows, models.Follow{FollowerID: val.FollowerID, FolloweeID: val.FolloweeID, CreatedAt: val.CreatedAt})
+	}
+
+	sessionRows, err := cfg.DB.ListSessionsForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading sessions: %w", err)
+	}
+	sessions := []models.Session{}
+	for _, val := range sessionRows {
+		sessions = append(sessions, models.Session{
+			CreatedAt: val.CreatedAt,
+			UpdatedAt: val.UpdatedAt,
+			ExpiresAt: nullTimePtr(val.ExpiresAt),
+			RevokedAt: nullTimePtr(val.RevokedAt),
+			ClientID:  val.ClientID.String,
+			Scopes:    val.Scopes,
+		})
+	}
+
+	tokenRows, err := cfg.DB.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading personal access tokens: %w", err)
+	}
+	tokens := []models.PersonalAccessToken{}
+	for _, val := range tokenRows {
+		tokens = append(tokens, toPersonalAccessToken(val))
+	}
+
+	consentRows, err := cfg.DB.ListOAuthConsents(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading oauth consents: %w", err)
+	}
+	consents := []models.OAuthConsent{}
+	for _, val := range consentRows {
+		consents = append(consents, models.OAuthConsent{
+			ClientID:   val.ClientID,
+			ClientName: val.ClientName,
+			Scopes:     val.Scopes,
+			CreatedAt:  val.CreatedAt,
+			UpdatedAt:  val.UpdatedAt,
+		})
+	}
+
+	blockRows, err := cfg.DB.ListBlocks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading blocks: %w", err)
+	}
+	blocks := []models.Relationship{}
+	for _, val := range blockRows {
+		blocks = append(blocks, models.Relationship{UserID: val.BlockedID, CreatedAt: val.CreatedAt})
+	}
+
+	muteRows, err := cfg.DB.ListMutes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading mutes: %w", err)
+	}
+	mutes := []models.Relationship{}
+	for _, val := range muteRows {
+		mutes = append(mutes, models.Relationship{UserID: val.MutedID, CreatedAt: val.CreatedAt})
+	}
+
+	return []export.File{
+		{Name: "profile.json", Data: profile},
+		{Name: "chirps.json", Data: chirps},
+		{Name: "likes.json", Data: likes},
+		{Name: "follows.json", Data: follows},
+		{Name: "sessions.json", Data: sessions},
+		{Name: "personal_access_tokens.json", Data: tokens},
+		{Name: "oauth_consents.json", Data: consents},
+		{Name: "blocks.json", Data: blocks},
+		{Name: "mutes.json", Data: mutes},
+	}, nil
+}
+
+func writeDataExport(ctx context.Context, cfg *config.ApiConfig, exportID, userID uuid.UUID) (string, error) {
+	files, err := collectExportFiles(ctx, cfg, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(cfg.ExportDir, 0o700); err != nil {
+		return "", fmt.Errorf("error creating export directory: %w", err)
+	}
+	path := filepath.Join(cfg.ExportDir, exportID.String()+".zip")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("error creating export file: %w", err)
+	}
+	if err := export.WriteZip(file, time.Now(), files); err != nil {
+		file.Close()
+		return path, err
+	}
+	return path, file.Close()
+}
+
+func GetDataExport(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve export Id : %v ", err))
+		return
+	}
+
+	dataExport, err := cfg.DB.GetDataExport(r.Context(), id)
+	if err != nil || dataExport.UserID != uuidUser {
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, toDataExport(cfg, dataExport))
+}
+
+// DownloadDataExport serves a finished archive. It is authorized by the signed
+// URL from GetDataExport rather than a session, so it can be opened directly
+// in a browser.
+func DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve export Id : %v ", err))
+		return
+	}
+	if err := auth.VerifyURLPath(exportDownloadPath(id), r.URL.Query(), cfg.SecretKey, time.Now()); err != nil {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	dataExport, err := cfg.DB.GetDataExport(r.Context(), id)
+	if err != nil || dataExport.Status != exportStatusReady || (dataExport.ExpiresAt.Valid && dataExport.ExpiresAt.Time.Before(time.Now())) {
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+
+	file, err := os.Open(dataExport.FilePath)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, dataExport.CreatedAt.Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Error sending export %s: %v", id, err)
+	}
+}
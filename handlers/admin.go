@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// parsePagination reads the limit and offset query parameters.
+func parsePagination(r *http.Request) (int32, int32, error) {
+	limit, offset := defaultPageSize, 0
+	var err error
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", value)
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", value)
+		}
+	}
+	return int32(min(limit, maxPageSize)), int32(offset), nil
+}
+
+// actorFromContext returns the authenticated user, or an invalid NullUUID
+// for requests authorized with the admin key.
+func actorFromContext(ctx context.Context) uuid.NullUUID {
+	userID, ok := ctx.Value(config.UserIDKey).(uuid.UUID)
+	return uuid.NullUUID{UUID: userID, Valid: ok}
+}
+
+func recordAdminAction(r *http.Request, cfg *config.ApiConfig, action, targetType, targetID string, details map[string]any) {
+	if details == nil {
+		details = map[string]any{}
+	}
+	raw, err := json.Marshal(details)
+	if err == nil {
+		err = cfg.DB.CreateAdminAction(r.Context(), database.CreateAdminActionParams{
+			ActorID:    actorFromContext(r.Context()),
+			Action:     action,
+			TargetType: targetType,
+			TargetID:   targetID,
+			Details:    raw,
+		})
+	}
+	if err != nil {
+		log.Printf("Error recording admin action %s on %s %s: %v", action, targetType, targetID, err)
+	}
+
+	recordAudit(r, cfg, audit.ActionAdminPrefix+action, actorFromContext(r.Context()), targetType, targetID, details)
+}
+
+// canSuspend reports whether actorRole may suspend, or lift the suspension
+// of, a user with targetRole. Moderators can suspend regular users only;
+// admins can suspend anyone below them.
+func canSuspend(actorRole, targetRole string) bool {
+	return targetRole == auth.RoleUser || (actorRole == auth.RoleAdmin && targetRole == auth.RoleModerator)
+}
+
+// suspendUser suspends uid and signs them out of every session.
+func suspendUser(ctx context.Context, cfg *config.ApiConfig, uid uuid.UUID, reason string) error {
+	_, err := cfg.DB.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedReason: sql.NullString{String: reason, Valid: reason != ""},
+		ID:              uid,
+	})
+	if err != nil {
+		return err
+	}
+	if err := cfg.DB.RevokeAllRefreshTokensForUser(ctx, uid); err != nil {
+		log.Printf("Error revoking sessions for user %s: %v", uid, err)
+	}
+	return nil
+}
+
+func AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	if role != "" && !auth.IsValidRole(role) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown role %q", role))
+		return
+	}
+
+	users, err := cfg.DB.ListUsers(r.Context(), database.ListUsersParams{
+		Query:  r.URL.Query().Get("q"),
+		Role:   role,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list users: %v", err))
+		return
+	}
+
+	returnUsers := []models.User{}
+	for _, val := range users {
+		returnUsers = append(returnUsers, models.User{
+			ID:              val.ID,
+			CreatedAt:       val.CreatedAt,
+			UpdatedAt:       val.UpdatedAt,
+			Email:           val.Email,
+			ChirpyRed:       val.IsChirpyRed,
+			EmailVerified:   val.EmailVerified,
+			Role:            val.Role,
+			SuspendedAt:     nullTimePtr(val.SuspendedAt),
+			SuspendedReason: val.SuspendedReason.String,
+		})
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnUsers)
+}
+
+func AdminSetUserRole(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Role string `json:"role"`
+	}
+
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !auth.IsValidRole(params.Role) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown role %q", params.Role))
+		return
+	}
+	if actor := actorFromContext(r.Context()); actor.Valid && actor.UUID == uid {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't change your own role")
+		return
+	}
+
+	result, err := cfg.DB.SetUserRole(r.Context(), database.SetUserRoleParams{Role: params.Role, ID: uid})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update role: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	recordAdminAction(r, cfg, "user.role_changed", "user", uid.String(), map[string]any{"role": params.Role})
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func AdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Reason string `json:"reason"`
+	}
+
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	target, err := cfg.DB.GetUserAuthState(r.Context(), uid)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	actorRole, _ := r.Context().Value(config.RoleKey).(string)
+	if !canSuspend(actorRole, target.Role) {
+		utils.RespondWithError(w, http.StatusForbidden, "You can't suspend this user")
+		return
+	}
+
+	err = suspendUser(r.Context(), cfg, uid, params.Reason)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to suspend user: %v", err))
+		return
+	}
+
+	recordAdminAction(r, cfg, "user.suspended", "user", uid.String(), map[string]any{"reason": params.Reason})
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func AdminUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	target, err := cfg.DB.GetUserAuthState(r.Context(), uid)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	actorRole, _ := r.Context().Value(config.RoleKey).(string)
+	if !canSuspend(actorRole, target.Role) {
+		utils.RespondWithError(w, http.StatusForbidden, "You can't unsuspend this user")
+		return
+	}
+
+	result, err := cfg.DB.UnsuspendUser(r.Context(), uid)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to unsuspend user: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	recordAdminAction(r, cfg, "user.unsuspended", "user", uid.String(), nil)
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func AdminDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	chirp, err := cfg.DB.GetChirpById(r.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("Chirp with ID %s not found", id))
+		return
+	}
+
+	_, err = cfg.DB.DeleteChirpById(r.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to delete chirp : %v ", err))
+		return
+	}
+
+	recordAdminAction(r, cfg, "chirp.deleted", "chirp", id.String(), map[string]any{"author_id": chirp.UserID, "body": chirp.Body})
+	if onPublicTimeline(chirp) {
+		notifyIntegrators(r.Context(), cfg, outbound.EventChirpDeleted, chirp.UserID, map[string]any{"id": chirp.ID, "user_id": chirp.UserID})
+		streamChirpDeleted(r.Context(), cfg, chirp.ID, chirp.UserID)
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
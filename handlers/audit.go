@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const auditExportBatchSize = 500
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordAudit appends an event to the audit log. Failures are logged rather
+// than surfaced, so auditing never breaks the action being audited.
+func recordAudit(r *http.Request, cfg *config.ApiConfig, action string, actor uuid.NullUUID, targetType, targetID string, details map[string]any) {
+	requestID, _ := r.Context().Value(config.RequestIDKey).(string)
+	_, err := cfg.Audit.Record(r.Context(), audit.Event{
+		Action:     action,
+		ActorID:    actor,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         clientIP(r),
+		RequestID:  requestID,
+		Details:    details,
+	})
+	if err != nil {
+		log.Printf("Error recording audit event %s: %v", action, err)
+	}
+}
+
+func toAuditEvent(e database.AuditEvent) models.AuditEvent {
+	var actor *uuid.UUID
+	if e.ActorID.Valid {
+		actor = &e.ActorID.UUID
+	}
+	return models.AuditEvent{
+		Seq:        e.Seq,
+		ID:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		Action:     e.Action,
+		ActorID:    actor,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.Ip,
+		RequestID:  e.RequestID,
+		Details:    e.Details,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
+
+// parseAuditFilters reads the audit event filters shared by the list and
+// export endpoints.
+func parseAuditFilters(r *http.Request) (database.ListAuditEventsParams, error) {
+	query := r.URL.Query()
+	params := database.ListAuditEventsParams{}
+
+	optional := func(name string) sql.NullString {
+		value := query.Get(name)
+		return sql.NullString{String: value, Valid: value != ""}
+	}
+	params.Action = optional("action")
+	params.TargetType = optional("target_type")
+	params.TargetID = optional("target_id")
+
+	if value := query.Get("actor_id"); value != "" {
+		actor, err := uuid.Parse(value)
+		if err != nil {
+			return params, fmt.Errorf("invalid actor_id %q", value)
+		}
+		params.ActorID = uuid.NullUUID{UUID: actor, Valid: true}
+	}
+	for name, target := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return params, fmt.Errorf("invalid %s %q, expected RFC 3339", name, value)
+			}
+			*target = sql.NullTime{Time: t.UTC(), Valid: true}
+		}
+	}
+	if value := query.Get("after_seq"); value != "" {
+		afterSeq, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || afterSeq < 0 {
+			return params, fmt.Errorf("invalid after_seq %q", value)
+		}
+		params.AfterSeq = afterSeq
+	}
+	return params, nil
+}
+
+func AdminListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Error to retrieve server configurations")
+	}
+
+	type responseBody struct {
+		Events       []models.AuditEvent `json:"events"`
+		NextAfterSeq int64               `json:"next_after_seq,omitempty"`
+	}
+
+	params, err := parseAuditFilters(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params.Limit, _, err = parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := cfg.DB.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list audit events: %v", err))
+		return
+	}
+
+	response := responseBody{Events: []models.AuditEvent{}}
+	for _, val := range events {
+		response.Events = append(response.Events, toAuditEvent(val))
+	}
+	if len(events) == int(params.Limit) {
+		response.NextAfterSeq = events[len(events)-1].Seq
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+// AdminExportAuditEvents streams every matching event as JSON Lines.
+func AdminExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Error to retrieve server configurations")
+	}
+
+	params, err := parseAuditFilters(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params.Limit = auditExportBatchSize
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.jsonl\"", time.Now().UTC().Format("20060102T150405Z")))
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	for {
+		events, err := cfg.DB.ListAuditEvents(r.Context(), params)
+		if err != nil {
+			log.Printf("Error exporting audit events after seq %d: %v", params.AfterSeq, err)
+			return
+		}
+		for _, val := range events {
+			if err := encoder.Encode(toAuditEvent(val)); err != nil {
+				return
+			}
+		}
+		if len(events) < int(params.Limit) {
+			return
+		}
+		params.AfterSeq = events[len(events)-1].Seq
+	}
+}
+
+// AdminVerifyAuditLog walks the whole hash chain and reports the first
+// event whose hash doesn't match.
+func AdminVerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Error to retrieve server configurations")
+	}
+
+	type responseBody struct {
+		Valid     bool  `json:"valid"`
+		Checked   int   `json:"checked"`
+		BrokenSeq int64 `json:"broken_seq,omitempty"`
+	}
+
+	params := database.ListAuditEventsParams{Limit: auditExportBatchSize}
+	response := responseBody{Valid: true}
+	prevHash := ""
+	for {
+		events, err := cfg.DB.ListAuditEvents(r.Context(), params)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to read audit events: %v", err))
+			return
+		}
+
+		var brokenSeq int64
+		var ok bool
+		prevHash, brokenSeq, ok = audit.VerifyChain(prevHash, events)
+		if !ok {
+			response.Valid = false
+			response.BrokenSeq = brokenSeq
+			break
+		}
+		response.Checked += len(events)
+		if len(events) < int(params.Limit) {
+			break
+		}
+		params.AfterSeq = events[len(events)-1].Seq
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
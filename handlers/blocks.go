@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// relationshipTarget reads the caller and the user in the path, refusing
+// requests that target the caller.
+func relationshipTarget(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig) (uuid.UUID, uuid.UUID, bool) {
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	target, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return uuid.Nil, uuid.Nil, false
+	}
+	if target == uuidUser {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't do this to yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	_, err = cfg.DB.GetUserById(r.Context(), target)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return uuidUser, target, true
+}
+
+func BlockUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, target, ok := relationshipTarget(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	err = cfg.DB.CreateBlock(r.Context(), database.CreateBlockParams{BlockerID: uuidUser, BlockedID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to block user: %v", err))
+		return
+	}
+	err = cfg.DB.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{FollowerID: uuidUser, FolloweeID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to remove follows: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func UnblockUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	target, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.DeleteBlock(r.Context(), database.DeleteBlockParams{BlockerID: uuidUser, BlockedID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to unblock user: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User is not blocked")
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func ListBlocks(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	blocks, err := cfg.DB.ListBlocks(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list blocks: %v", err))
+		return
+	}
+
+	returnBlocks := []models.Relationship{}
+	for _, val := range blocks {
+		returnBlocks = append(returnBlocks, models.Relationship{UserID: val.BlockedID, CreatedAt: val.CreatedAt})
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnBlocks)
+}
+
+func MuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, target, ok := relationshipTarget(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	err = cfg.DB.CreateMute(r.Context(), database.CreateMuteParams{MuterID: uuidUser, MutedID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to mute user: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	target, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.DeleteMute(r.Context(), database.DeleteMuteParams{MuterID: uuidUser, MutedID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to unmute user: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User is not muted")
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func ListMutes(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	mutes, err := cfg.DB.ListMutes(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list mutes: %v", err))
+		return
+	}
+
+	returnMutes := []models.Relationship{}
+	for _, val := range mutes {
+		returnMutes = append(returnMutes, models.Relationship{UserID: val.MutedID, CreatedAt: val.CreatedAt})
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnMutes)
+}
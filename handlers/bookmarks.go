@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// BookmarkChirp saves a chirp the caller can see to their bookmarks.
+// Bookmarking a chirp twice is not an error.
+func BookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id := r.PathValue("id")
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	chirp, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: uid, ViewerID: actorFromContext(r.Context())})
+	if err != nil || chirp.Status != "published" {
+		utils.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("Chirp with ID %s not found", id))
+		return
+	}
+
+	err = cfg.DB.CreateBookmark(r.Context(), database.CreateBookmarkParams{UserID: uuidUser, ChirpID: chirp.ID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to bookmark chirp: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func UnbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{UserID: uuidUser, ChirpID: uid})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to remove bookmark: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp is not bookmarked")
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+// ListBookmarks lists the caller's bookmarked chirps in the order they were
+// bookmarked, oldest first unless sort=desc, a page at a time.
+func ListBookmarks(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.DB.ListBookmarkedChirps(r.Context(), database.ListBookmarkedChirpsParams{
+		UserID:      uuidUser,
+		NewestFirst: r.URL.Query().Get("sort") == "desc",
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list bookmarks: %v", err))
+		return
+	}
+
+	returnChirps := []models.Chirp{}
+	for _, val := range chirps {
+		returnChirps = append(returnChirps, toChirp(val))
+	}
+	if err := decorateChirps(r.Context(), cfg, uuid.NullUUID{UUID: uuidUser, Valid: true}, returnChirps); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list chirp details: %v", err))
+		return
+	}
+	utils.RespondWithListJson(w, http.StatusOK, returnChirps)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/chirpstream"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/pubsub"
+	"github.com/leonardoklaser/Chirpy/internal/websocket"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	// chirpEventsChannel is the Postgres NOTIFY channel of the chirp stream.
+	chirpEventsChannel = "chirp_events"
+	// chirpEventRetention is how long a disconnected client can resume.
+	chirpEventRetention = 24 * time.Hour
+	// chirpStreamReplayLimit caps the missed events sent to a resuming
+	// client. A client further behind gets a stream.reset event and can list
+	// chirps to fill the gap.
+	chirpStreamReplayLimit = 500
+)
+
+func toStreamEvent(e database.ChirpEvent) chirpstream.Event {
+	return chirpstream.Event{
+		Seq:      e.Seq,
+		Type:     e.Type,
+		ChirpID:  e.ChirpID,
+		UserID:   e.UserID,
+		Hashtags: e.Hashtags,
+		Payload:  []byte(e.Payload),
+	}
+}
+
+// appendChirpEvent adds an event to the chirp stream. The database notifies
+// every instance, this one included, so the event reaches clients through
+// RelayChirpEvents. Failures are logged: the change itself already happened.
+func appendChirpEvent(ctx context.Context, cfg *config.ApiConfig, eventType string, chirpID, userID uuid.UUID, hashtags []string, data any) {
+	payload, err := json.Marshal(data)
+	if err == nil {
+		err = insertChirpEvent(ctx, cfg, database.CreateChirpEventParams{
+			Type:     eventType,
+			ChirpID:  chirpID,
+			UserID:   userID,
+			Hashtags: hashtags,
+			Payload:  string(payload),
+		})
+	}
+	if err != nil {
+		log.Printf("Error streaming %s of chirp %s: %v", eventType, chirpID, err)
+	}
+}
+
+// insertChirpEvent inserts an event under the stream lock. seq is drawn when
+// the row is inserted, but without the lock a smaller seq could commit after
+// a larger one and be skipped by readers that already moved past it.
+func insertChirpEvent(ctx context.Context, cfg *config.ApiConfig, params database.CreateChirpEventParams) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := qtx.LockChirpEvents(ctx); err != nil {
+		return err
+	}
+	if err := qtx.CreateChirpEvent(ctx, params); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// streamChirpCreated adds a newly published chirp to the chirp stream.
+func streamChirpCreated(ctx context.Context, cfg *config.ApiConfig, chirp database.Chirp) {
+	response := []models.Chirp{toChirp(chirp)}
+	if err := decorateChirps(ctx, cfg, uuid.NullUUID{}, response); err != nil {
+		log.Printf("Error loading details of chirp %s: %v", chirp.ID, err)
+	}
+	appendChirpEvent(ctx, cfg, chirpstream.TypeCreated, chirp.ID, chirp.UserID, chirpstream.ExtractHashtags(chirp.Body), response[0])
+}
+
+// onPublicTimeline reports whether chirp was announced and not yet removed,
+// so deleting it has to be announced. Drafts and scheduled chirps never were,
+// and hiding a chirp already announced its removal.
+func onPublicTimeline(chirp database.Chirp) bool {
+	return chirp.Status == chirpStatusPublished && !chirp.HiddenAt.Valid
+}
+
+// streamChirpDeleted tells the chirp stream a chirp left the public timeline,
+// because it was deleted or hidden by a moderator.
+func streamChirpDeleted(ctx context.Context, cfg *config.ApiConfig, chirpID, userID uuid.UUID) {
+	appendChirpEvent(ctx, cfg, chirpstream.TypeDeleted, chirpID, userID, []string{}, map[string]any{"id": chirpID, "user_id": userID})
+}
+
+// RelayChirpEvents publishes the chirp events committed by any instance to
+// the streams open on this one, until ctx is done.
+func RelayChirpEvents(ctx context.Context, cfg *config.ApiConfig) error {
+	lastSeq, err := cfg.DB.GetLatestChirpEventSeq(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading chirp stream position: %w", err)
+	}
+
+	return pubsub.Listen(ctx, cfg.DatabaseURL, chirpEventsChannel, func(payload string) {
+		if payload == "" {
+			lastSeq = catchUpChirpEvents(ctx, cfg, lastSeq)
+			return
+		}
+		seq, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			log.Printf("Invalid chirp event notification %q", payload)
+			return
+		}
+		event, err := cfg.DB.GetChirpEvent(ctx, seq)
+		if err != nil {
+			log.Printf("Error loading chirp event %d: %v", seq, err)
+			return
+		}
+		cfg.ChirpEvents.Publish(chirpstream.Topic, toStreamEvent(event))
+		lastSeq = max(lastSeq, seq)
+	})
+}
+
+// catchUpChirpEvents publishes the events after lastSeq, which were missed
+// while the connection was down, and returns the new position.
+func catchUpChirpEvents(ctx context.Context, cfg *config.ApiConfig, lastSeq int64) int64 {
+	for {
+		events, err := cfg.DB.ListChirpEventsAfter(ctx, database.ListChirpEventsAfterParams{Seq: lastSeq, Limit: chirpStreamReplayLimit})
+		if err != nil {
+			log.Printf("Error catching up on chirp events: %v", err)
+			return lastSeq
+		}
+		for _, event := range events {
+			cfg.ChirpEvents.Publish(chirpstream.Topic, toStreamEvent(event))
+			lastSeq = event.Seq
+		}
+		if len(events) < chirpStreamReplayLimit {
+			return lastSeq
+		}
+	}
+}
+
+// PurgeChirpEvents deletes the stream events too old to resume from.
+func PurgeChirpEvents(ctx context.Context, cfg *config.ApiConfig) error {
+	_, err := cfg.DB.DeleteChirpEventsBefore(ctx, time.Now().UTC().Add(-chirpEventRetention))
+	return err
+}
+
+// chirpStreamFilter reads the author_id and hashtag filters of a stream
+// request, and leaves out the authors the caller blocked or was blocked by.
+// Like ListChirps, mutes are ignored when following a single author.
+func chirpStreamFilter(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig) (chirpstream.Filter, bool) {
+	filter := chirpstream.Filter{Excluded: map[uuid.UUID]bool{}}
+	if author := r.URL.Query().Get("author_id"); author != "" {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve Author Id : %v ", err))
+			return filter, false
+		}
+		filter.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+	if tag := r.URL.Query().Get("hashtag"); tag != "" {
+		hashtag, err := chirpstream.NormalizeHashtag(tag)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return filter, false
+		}
+		filter.Hashtag = hashtag
+	}
+
+	viewer := actorFromContext(r.Context())
+	if !viewer.Valid {
+		return filter, true
+	}
+	exclusions, err := cfg.DB.ListTimelineExclusions(r.Context(), viewer.UUID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list blocked users: %v", err))
+		return filter, false
+	}
+	for _, val := range exclusions {
+		if !val.Muted || !filter.AuthorID.Valid {
+			filter.Excluded[val.UserID] = true
+		}
+	}
+	return filter, true
+}
+
+// lastEventID returns where a reconnecting client left off: the
+// Last-Event-ID header browsers send for SSE, or the last_event_id query
+// parameter. Zero means the client only wants new events.
+func lastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("invalid last event id %q", value)
+	}
+	return seq, nil
+}
+
+// chirpStream is an open subscription to the chirp stream together with the
+// events a resuming client missed. Live events up to after were already
+// covered by the backlog.
+type chirpStream struct {
+	filter  chirpstream.Filter
+	live    <-chan chirpstream.Event
+	cancel  func()
+	backlog []chirpstream.Event
+	after   int64
+}
+
+// openChirpStream subscribes before reading the missed events, so nothing
+// committed in between is lost. Events can then arrive twice; run skips
+// the repeats. When the missed events were purged or are more than
+// chirpStreamReplayLimit, the backlog is a single stream.reset event that
+// moves the client to the latest seq instead.
+func openChirpStream(ctx context.Context, cfg *config.ApiConfig, filter chirpstream.Filter, lastSeq int64) (*chirpStream, error) {
+	live, cancel := cfg.ChirpEvents.Subscribe(chirpstream.Topic)
+	stream := &chirpStream{filter: filter, live: live, cancel: cancel, after: lastSeq}
+	if lastSeq == 0 {
+		return stream, nil
+	}
+
+	latest, err := cfg.DB.GetLatestChirpEventSeq(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if lastSeq >= latest {
+		return stream, nil
+	}
+	oldest, err := cfg.DB.GetOldestChirpEventSeq(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	events, err := cfg.DB.ListChirpEventsAfter(ctx, database.ListChirpEventsAfterParams{Seq: lastSeq, Limit: chirpStreamReplayLimit + 1})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if oldest > lastSeq+1 || len(events) > chirpStreamReplayLimit {
+		stream.backlog = []chirpstream.Event{{Seq: latest, Type: chirpstream.TypeReset, Payload: []byte("{}")}}
+		stream.after = latest
+		return stream, nil
+	}
+	for _, val := range events {
+		if event := toStreamEvent(val); filter.Matches(event) {
+			stream.backlog = append(stream.backlog, event)
+		}
+	}
+	return stream, nil
+}
+
+// run sends the backlog and then the live events matching the filter until
+// done is closed, sending fails, or the hub is closed on shutdown. heartbeat
+// is called when the stream has been idle for streamHeartbeat.
+func (s *chirpStream) run(done <-chan struct{}, send func(chirpstream.Event) error, heartbeat func() error) {
+	defer s.cancel()
+
+	sent := map[int64]bool{}
+	for _, event := range s.backlog {
+		if err := send(event); err != nil {
+			return
+		}
+		sent[event.Seq] = true
+	}
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-s.live:
+			if !ok {
+				return
+			}
+			if event.Seq <= s.after || sent[event.Seq] || !s.filter.Matches(event) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StreamChirps sends chirp.created and chirp.deleted events as server-sent
+// events. Each event id can be sent back as Last-Event-ID to resume after
+// a disconnect; a client too far behind gets a stream.reset event.
+func StreamChirps(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	filter, ok := chirpStreamFilter(w, r, cfg)
+	if !ok {
+		return
+	}
+	lastSeq, err := lastEventID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stream, err := openChirpStream(r.Context(), cfg, filter, lastSeq)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to open chirp stream: %v", err))
+		return
+	}
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		stream.cancel()
+		return
+	}
+	stream.run(r.Context().Done(), func(event chirpstream.Event) error {
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, event.Payload)
+		flusher.Flush()
+		return err
+	}, func() error {
+		_, err := fmt.Fprint(w, ": heartbeat\n\n")
+		flusher.Flush()
+		return err
+	})
+}
+
+// StreamChirpsWebSocket sends the chirp stream over a WebSocket, one JSON
+// message per event. Clients resume with the last_event_id query parameter.
+func StreamChirpsWebSocket(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	if !websocket.IsUpgrade(r) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Expected a WebSocket upgrade")
+		return
+	}
+	filter, ok := chirpStreamFilter(w, r, cfg)
+	if !ok {
+		return
+	}
+	lastSeq, err := lastEventID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stream, err := openChirpStream(r.Context(), cfg, filter, lastSeq)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to open chirp stream: %v", err))
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		stream.cancel()
+		return
+	}
+	defer conn.Close()
+
+	stream.run(conn.Done(), func(event chirpstream.Event) error {
+		message, err := json.Marshal(models.ChirpStreamMessage{ID: event.Seq, Type: event.Type, Data: event.Payload})
+		if err != nil {
+			return err
+		}
+		return conn.WriteText(message)
+	}, conn.Ping)
+}
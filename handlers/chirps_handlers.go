@@ -1,18 +1,70 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sort"
+	"time"
 	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
 	"github.com/leonardoklaser/Chirpy/internal/config"
 	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/internal/poll"
 	"github.com/leonardoklaser/Chirpy/models"
 	"github.com/leonardoklaser/Chirpy/utils"
 )
 
+// Profile tabs of GET /api/chirps?author_id=. Each is served by its own
+// query; without a tab the author's chirps and replies are listed.
+const (
+	tabChirps  = "chirps"
+	tabReplies = "replies"
+	tabMedia   = "media"
+	tabLikes   = "likes"
+)
+
+// toChirp converts a stored chirp. Hidden chirps, drafts and scheduled chirps
+// only ever reach their author, since the queries leave them out for
+// everyone else.
+func toChirp(chirp database.Chirp) models.Chirp {
+	return models.Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserId:    chirp.UserID,
+		Hidden:    chirp.HiddenAt.Valid,
+		Status:    chirp.Status,
+		PublishAt: nullTimePtr(chirp.PublishAt),
+		EditedAt:  nullTimePtr(chirp.EditedAt),
+		ReplyToID: nullUUIDPtr(chirp.ReplyToID),
+	}
+}
+
+// decorateChirps adds the attachments, polls and author badges of chirps, as
+// seen by viewer.
+func decorateChirps(ctx context.Context, cfg *config.ApiConfig, viewer uuid.NullUUID, chirps []models.Chirp) error {
+	if err := withMedia(ctx, cfg, chirps); err != nil {
+		return err
+	}
+	if err := withBadges(ctx, cfg, chirps); err != nil {
+		return err
+	}
+	return withPolls(ctx, cfg, viewer, chirps)
+}
+
+// cleanChirpBody masks the banned words in body.
+func cleanChirpBody(body string) (string, error) {
+	replacer := strings.NewReplacer("kerfuffle", "****", "sharbert", "****", "fornax", "****")
+	cleanedBody := replacer.Replace(strings.ToLower(body))
+	return utils.FormatProfane(body, cleanedBody)
+}
 
 func HandlerValidateChirp(w http.ResponseWriter, r *http.Request) {
 
@@ -31,7 +83,12 @@ func HandlerValidateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(params.Body) > 140 {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	if len(params.Body) > cfg.Entitlements.Free.MaxChirpLength {
 		utils.RespondWithError(w, http.StatusBadRequest, "Chirp is too long")
 		return
 	}
@@ -50,6 +107,42 @@ func HandlerValidateChirp(w http.ResponseWriter, r *http.Request) {
 }
 
 
+// authorChirps lists the chirps of author's profile tab as seen by viewer.
+// The likes tab lists the chirps author liked rather than wrote.
+func authorChirps(ctx context.Context, cfg *config.ApiConfig, author uuid.UUID, viewer uuid.NullUUID, tab string) ([]models.Chirp, error) {
+	var pinned []database.GetChirpsByUserIdRow
+	var chirps []database.Chirp
+	var err error
+	switch tab {
+	case tabChirps:
+		var rows []database.GetChirpsByUserIdWithoutRepliesRow
+		rows, err = cfg.DB.GetChirpsByUserIdWithoutReplies(ctx, database.GetChirpsByUserIdWithoutRepliesParams{UserID: author, ViewerID: viewer})
+		for _, val := range rows {
+			pinned = append(pinned, database.GetChirpsByUserIdRow(val))
+		}
+	case tabMedia:
+		chirps, err = cfg.DB.GetMediaChirpsByUserId(ctx, database.GetMediaChirpsByUserIdParams{UserID: author, ViewerID: viewer})
+	case tabLikes:
+		chirps, err = cfg.DB.GetChirpsLikedByUser(ctx, database.GetChirpsLikedByUserParams{UserID: author, ViewerID: viewer})
+	default:
+		pinned, err = cfg.DB.GetChirpsByUserId(ctx, database.GetChirpsByUserIdParams{UserID: author, ViewerID: viewer})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var returnChirps []models.Chirp
+	for _, val := range pinned {
+		chirp := toChirp(val.Chirp)
+		chirp.Pinned = val.Pinned
+		returnChirps = append(returnChirps, chirp)
+	}
+	for _, val := range chirps {
+		returnChirps = append(returnChirps, toChirp(val))
+	}
+	return returnChirps, nil
+}
+
 func ListChirps(w http.ResponseWriter, r *http.Request) {
 	
 	var err error
@@ -59,46 +152,62 @@ func ListChirps(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
 	}
 
+	// Blocks and mutes are applied by the queries. Mutes only thin out the
+	// timeline, so an explicit author_id still shows a muted user's chirps.
 	author := r.URL.Query().Get("author_id")
-	var chirps []database.Chirp
+	tab := r.URL.Query().Get("tab")
+	viewer := actorFromContext(r.Context())
+	var returnChirps []models.Chirp
 	
 	if author == ""{
-		chirps, err = cfg.DB.GetAllChirps(r.Context())
+		if tab != "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Profile tabs need an author_id")
+			return
+		}
+		chirps, err := cfg.DB.GetAllChirps(r.Context(), viewer)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list all chirps: %v", err))
 			return
 		}
+		for _, val := range chirps {
+			returnChirps = append(returnChirps, toChirp(val))
+		}
 	}else{
 		authoruuid, err := uuid.Parse(author)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve Author Id : %v ", err))
 			return
 		}	
-		chirps, err = cfg.DB.GetChirpsByUserId(r.Context(), authoruuid)
+		switch tab {
+		case "", tabChirps, tabReplies, tabMedia, tabLikes:
+		default:
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown tab %q", tab))
+			return
+		}
+		returnChirps, err = authorChirps(r.Context(), cfg, authoruuid, viewer, tab)
 		if err != nil {
                         utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list all chirps: %v", err))
                         return
                 }
 	}
-	var returnChirps []models.Chirp
 
-	for _, val := range chirps {
-		newChirp := models.Chirp{
-			ID:        val.ID,
-			CreatedAt: val.CreatedAt,
-			UpdatedAt: val.UpdatedAt,
-			Body:      val.Body,
-			UserId:    val.UserID,
-		}
-		returnChirps = append(returnChirps, newChirp)
+	if err := decorateChirps(r.Context(), cfg, viewer, returnChirps); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list chirp details: %v", err))
+		return
 	}
 	
 
+	// The queries return each list in its own order: oldest first, or most
+	// recently liked first for the likes tab. A pinned chirp stays on top
+	// whichever way the rest are sorted.
 	sortSlice := r.URL.Query().Get("sort")
 	if sortSlice == "desc"{
-		sort.Slice(returnChirps, func(i, j int) bool { return returnChirps[i].CreatedAt.After(returnChirps[j].CreatedAt)})
-	}else {
-		sort.Slice(returnChirps, func(i, j int) bool { return returnChirps[i].CreatedAt.Before(returnChirps[j].CreatedAt)})
+		sort.Slice(returnChirps, func(i, j int) bool {
+			if returnChirps[i].Pinned != returnChirps[j].Pinned {
+				return returnChirps[i].Pinned
+			}
+			return returnChirps[i].CreatedAt.After(returnChirps[j].CreatedAt)
+		})
 	}
 	utils.RespondWithListJson(w, http.StatusOK, returnChirps)
 }
@@ -116,20 +225,18 @@ func GetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.DB.GetChirpById(r.Context(), uid)
+	chirp, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: uid, ViewerID: actorFromContext(r.Context())})
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("Chirp with ID %s not found", id))
 		return
 	}
 
-	chirpToReturn := models.Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
+	response := []models.Chirp{toChirp(chirp)}
+	if err := decorateChirps(r.Context(), cfg, actorFromContext(r.Context()), response); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list chirp details: %v", err))
+		return
 	}
-	utils.RespondWithJson(w, http.StatusOK, chirpToReturn)
+	utils.RespondWithJson(w, http.StatusOK, response[0])
 }
 
 func PostChirps(w http.ResponseWriter, r *http.Request) {
@@ -139,8 +246,16 @@ func PostChirps(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type requestBody struct {
-		Body   string    `json:"body"`
-		UserID uuid.UUID `json:"user_id"`
+		Body      string      `json:"body"`
+		UserID    uuid.UUID   `json:"user_id"`
+		MediaIDs  []uuid.UUID `json:"media_ids"`
+		Draft     bool        `json:"draft"`
+		PublishAt *time.Time  `json:"publish_at"`
+		ReplyToID *uuid.UUID  `json:"reply_to_id"`
+		Poll      *struct {
+			Options  []string  `json:"options"`
+			ClosesAt time.Time `json:"closes_at"`
+		} `json:"poll"`
 	}
 
 	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
@@ -156,33 +271,231 @@ func PostChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(params.Body) > 140 {
-		utils.RespondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	tier := entitlementsFor(r.Context(), cfg, uuidUser)
+	if len(params.Body) > tier.MaxChirpLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %d characters", tier.MaxChirpLength))
 		return
 	}
 
-	replacer := strings.NewReplacer("kerfuffle", "****", "sharbert", "****", "fornax", "****")
-	cleanedBody := replacer.Replace(strings.ToLower(params.Body))
-	responseCleanedBody, err := utils.FormatProfane(params.Body, cleanedBody)
+	status, publishAt, err := chirpSchedule(params.Draft, params.PublishAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if params.Poll != nil {
+		// A draft has no publish time to measure the poll against.
+		if status == chirpStatusDraft {
+			utils.RespondWithError(w, http.StatusBadRequest, "Polls can't be added to drafts")
+			return
+		}
+		opensAt := time.Now()
+		if publishAt.Valid {
+			opensAt = publishAt.Time
+		}
+		if err := poll.Validate(params.Poll.Options, params.Poll.ClosesAt, opensAt); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	replyTo := uuid.NullUUID{}
+	if params.ReplyToID != nil {
+		parent, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: *params.ReplyToID, ViewerID: uuid.NullUUID{UUID: uuidUser, Valid: true}})
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && parent.Status != chirpStatusPublished) {
+			utils.RespondWithError(w, http.StatusBadRequest, "The chirp to reply to was not found")
+			return
+		}
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve chirp: %v", err))
+			return
+		}
+		replyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	if len(params.MediaIDs) > tier.MaxMediaPerChirp {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d attachments", tier.MaxMediaPerChirp))
+		return
+	}
+	seenMedia := map[uuid.UUID]bool{}
+	for _, id := range params.MediaIDs {
+		if seenMedia[id] {
+			utils.RespondWithError(w, http.StatusBadRequest, "Duplicate media_ids")
+			return
+		}
+		seenMedia[id] = true
+	}
+
+	if cfg.UnverifiedChirpLimit >= 0 {
+		user, err := cfg.DB.GetUserById(r.Context(), uuidUser)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+		if !user.EmailVerified {
+			count, err := cfg.DB.CountChirpsByUserId(r.Context(), uuidUser)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to count chirps: %v", err))
+				return
+			}
+			if count >= int64(cfg.UnverifiedChirpLimit) {
+				utils.RespondWithError(w, http.StatusForbidden, "Verify your email to post more chirps")
+				return
+			}
+		}
+	}
+
+	if tier.ChirpsPerHour > 0 {
+		count, err := cfg.DB.CountChirpsSince(r.Context(), database.CountChirpsSinceParams{UserID: uuidUser, Since: time.Now().UTC().Add(-time.Hour)})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to count chirps: %v", err))
+			return
+		}
+		if count >= int64(tier.ChirpsPerHour) {
+			w.Header().Set("Retry-After", "60")
+			utils.RespondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("You can post at most %d chirps an hour", tier.ChirpsPerHour))
+			return
+		}
+	}
+
+	responseCleanedBody, err := cleanChirpBody(params.Body)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error to format profane words")
 		return
 	}
 
-	createChirpParam := database.CreateChirpParams{Body: responseCleanedBody, UserID: uuidUser}
+	createChirpParam := database.CreateChirpParams{Body: responseCleanedBody, UserID: uuidUser, Status: status, PublishAt: publishAt, ReplyToID: replyTo}
 
-	chirp, err := cfg.DB.CreateChirp(r.Context(), createChirpParam)
+	// The chirp, its attachments and its poll are written together so a bad
+	// media id doesn't leave a text-only chirp behind.
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to Create new Chirp: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	chirp, err := qtx.CreateChirp(r.Context(), createChirpParam)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to Create new Chirp: %v, json: %v", err, createChirpParam) )
 		return
 	}
 
-	response := models.Chirp{ID: chirp.ID, CreatedAt: chirp.CreatedAt, UpdatedAt: chirp.UpdatedAt, Body: chirp.Body, UserId: chirp.UserID}
-	utils.RespondWithJson(w, http.StatusCreated, response)
+	if len(params.MediaIDs) > 0 {
+		result, err := qtx.AttachMediaToChirp(r.Context(), database.AttachMediaToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			MediaIds: params.MediaIDs,
+			UserID:   uuidUser,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to attach media: %v", err))
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows != int64(len(params.MediaIDs)) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown or already attached media_ids")
+			return
+		}
+	}
+
+	if params.Poll != nil {
+		err = qtx.CreatePoll(r.Context(), database.CreatePollParams{ChirpID: chirp.ID, ClosesAt: params.Poll.ClosesAt.UTC()})
+		if err == nil {
+			labels := make([]string, 0, len(params.Poll.Options))
+			for _, option := range params.Poll.Options {
+				labels = append(labels, strings.TrimSpace(option))
+			}
+			err = qtx.CreatePollOptions(r.Context(), database.CreatePollOptionsParams{ChirpID: chirp.ID, Labels: labels})
+		}
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create poll: %v", err))
+			return
+		}
+	}
+
+	if chirp.Status == chirpStatusPublished {
+		if err := queueEvent(r.Context(), qtx, outbound.EventChirpCreated, uuidUser, toChirp(chirp)); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to queue webhooks: %v", err))
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to Create new Chirp: %v", err))
+		return
+	}
+	if chirp.Status == chirpStatusPublished {
+		notifyChirpPublished(r.Context(), cfg, chirp)
+		streamChirpCreated(r.Context(), cfg, chirp)
+	}
+
+	respondChirp(w, r, cfg, http.StatusCreated, chirp)
 
 }
 
 
+// EditChirp changes the body of a published chirp. Editing is an entitlement,
+// and edited chirps carry edited_at so readers can tell.
+func EditChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	type requestBody struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return
+	}
+
+	tier := entitlementsFor(r.Context(), cfg, uuidUser)
+	if !tier.CanEditChirps {
+		utils.RespondWithError(w, http.StatusForbidden, "Your plan doesn't include editing chirps")
+		return
+	}
+	if len(params.Body) > tier.MaxChirpLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %d characters", tier.MaxChirpLength))
+		return
+	}
+
+	cleanedBody, err := cleanChirpBody(params.Body)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error to format profane words")
+		return
+	}
+
+	chirp, err := cfg.DB.EditPublishedChirp(r.Context(), database.EditPublishedChirpParams{ID: id, UserID: uuidUser, Body: cleanedBody})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to edit chirp: %v", err))
+		return
+	}
+
+	respondChirp(w, r, cfg, http.StatusOK, chirp)
+}
+
+
 func DeleteChirpById(w http.ResponseWriter, r *http.Request){
 	
 	cfg, err := config.New()
@@ -218,6 +531,12 @@ func DeleteChirpById(w http.ResponseWriter, r *http.Request){
 		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to delete chirp : %v ", err))
                 return
 	}
+
+	recordAudit(r, cfg, audit.ActionChirpDeleted, actorFromContext(r.Context()), "chirp", chirp.ID.String(), map[string]any{"author_id": chirp.UserID})
+	if onPublicTimeline(chirp) {
+		notifyIntegrators(r.Context(), cfg, outbound.EventChirpDeleted, chirp.UserID, map[string]any{"id": chirp.ID, "user_id": chirp.UserID})
+		streamChirpDeleted(r.Context(), cfg, chirp.ID, chirp.UserID)
+	}
 	
 	var nullInterface interface{}
 
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/internal/poll"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	chirpStatusDraft     = "draft"
+	chirpStatusScheduled = "scheduled"
+	chirpStatusPublished = "published"
+)
+
+// chirpSchedule works out the status of a chirp from the request: a future
+// publish_at schedules it, draft keeps it unpublished, and otherwise it is
+// published straight away.
+func chirpSchedule(draft bool, publishAt *time.Time) (string, sql.NullTime, error) {
+	if publishAt == nil {
+		if draft {
+			return chirpStatusDraft, sql.NullTime{}, nil
+		}
+		return chirpStatusPublished, sql.NullTime{}, nil
+	}
+	if draft {
+		return "", sql.NullTime{}, errors.New("a chirp can't be both a draft and scheduled")
+	}
+	if !publishAt.After(time.Now()) {
+		return "", sql.NullTime{}, errors.New("publish_at must be in the future")
+	}
+	return chirpStatusScheduled, sql.NullTime{Time: publishAt.UTC(), Valid: true}, nil
+}
+
+func ListDrafts(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	chirps, err := cfg.DB.ListUnpublishedChirps(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list drafts: %v", err))
+		return
+	}
+
+	returnChirps := []models.Chirp{}
+	for _, val := range chirps {
+		returnChirps = append(returnChirps, toChirp(val))
+	}
+	if err := decorateChirps(r.Context(), cfg, uuid.NullUUID{UUID: uuidUser, Valid: true}, returnChirps); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list chirp details: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnChirps)
+}
+
+// UpdateDraft replaces the body and schedule of a draft or scheduled chirp.
+// Leaving out publish_at turns a scheduled chirp back into a draft.
+func UpdateDraft(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	type requestBody struct {
+		Body      string     `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return
+	}
+
+	tier := entitlementsFor(r.Context(), cfg, uuidUser)
+	if len(params.Body) > tier.MaxChirpLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %d characters", tier.MaxChirpLength))
+		return
+	}
+	status, publishAt, err := chirpSchedule(params.PublishAt == nil, params.PublishAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A poll has to close a sensible time after the chirp goes out, so a
+	// chirp with a poll can only be rescheduled, not turned back into a draft.
+	existingPoll, err := cfg.DB.GetPoll(r.Context(), id)
+	if err == nil {
+		if !publishAt.Valid {
+			utils.RespondWithError(w, http.StatusBadRequest, "A chirp with a poll needs a publish_at")
+			return
+		}
+		if err := poll.ValidateDuration(existingPoll.ClosesAt, publishAt.Time); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve poll: %v", err))
+		return
+	}
+
+	cleanedBody, err := cleanChirpBody(params.Body)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error to format profane words")
+		return
+	}
+
+	chirp, err := cfg.DB.UpdateUnpublishedChirp(r.Context(), database.UpdateUnpublishedChirpParams{
+		ID:        id,
+		UserID:    uuidUser,
+		Body:      cleanedBody,
+		Status:    status,
+		PublishAt: publishAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update draft: %v", err))
+		return
+	}
+
+	respondChirp(w, r, cfg, http.StatusOK, chirp)
+}
+
+func PublishDraft(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	chirp, err := cfg.DB.PublishChirp(r.Context(), database.PublishChirpParams{ID: id, UserID: uuidUser})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to publish chirp: %v", err))
+		return
+	}
+	notifyIntegrators(r.Context(), cfg, outbound.EventChirpCreated, chirp.UserID, toChirp(chirp))
+	notifyChirpPublished(r.Context(), cfg, chirp)
+	streamChirpCreated(r.Context(), cfg, chirp)
+
+	respondChirp(w, r, cfg, http.StatusOK, chirp)
+}
+
+// respondChirp writes a single chirp together with its attachments and poll.
+func respondChirp(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, status int, chirp database.Chirp) {
+	response := []models.Chirp{toChirp(chirp)}
+	if err := decorateChirps(r.Context(), cfg, actorFromContext(r.Context()), response); err != nil {
+		log.Printf("Error loading details of chirp %s: %v", chirp.ID, err)
+	}
+	utils.RespondWithJson(w, status, response[0])
+}
+
+// PublishScheduledChirps publishes every scheduled chirp that is due. The
+// schedule lives in the database, so chirps that came due while the server
+// was down go out on the first run after a restart.
+func PublishScheduledChirps(ctx context.Context, cfg *config.ApiConfig) error {
+	chirps, err := cfg.DB.PublishDueChirps(ctx)
+	if err != nil {
+		return fmt.Errorf("error publishing scheduled chirps: %w", err)
+	}
+	if len(chirps) > 0 {
+		log.Printf("Published %d scheduled chirps", len(chirps))
+	}
+	for _, chirp := range chirps {
+		notifyIntegrators(ctx, cfg, outbound.EventChirpCreated, chirp.UserID, toChirp(chirp))
+		notifyChirpPublished(ctx, cfg, chirp)
+		streamChirpCreated(ctx, cfg, chirp)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/entitlements"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// entitlementsFor returns the tier userID currently belongs to.
+func entitlementsFor(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) entitlements.Tier {
+	return cfg.Entitlements.For(chirpyRed(ctx, cfg, userID))
+}
+
+// withBadges sets the badge of chirps whose author is a Chirpy Red member,
+// looking all authors up with a single query.
+func withBadges(ctx context.Context, cfg *config.ApiConfig, chirps []models.Chirp) error {
+	badge := cfg.Entitlements.Red.Badge
+	if len(chirps) == 0 || badge == "" {
+		return nil
+	}
+	authors := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		authors = append(authors, chirp.UserId)
+	}
+
+	members, err := cfg.DB.ListChirpyRedUsers(ctx, authors)
+	if err != nil {
+		return err
+	}
+	red := map[uuid.UUID]bool{}
+	for _, id := range members {
+		red[id] = true
+	}
+	for i := range chirps {
+		if red[chirps[i].UserId] {
+			chirps[i].Badge = badge
+		}
+	}
+	return nil
+}
+
+func GetEntitlements(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	red := chirpyRed(r.Context(), cfg, uuidUser)
+	tier := cfg.Entitlements.For(red)
+	utils.RespondWithJson(w, http.StatusOK, models.Entitlements{
+		ChirpyRed:        red,
+		MaxChirpLength:   tier.MaxChirpLength,
+		MaxMediaPerChirp: tier.MaxMediaPerChirp,
+		ChirpsPerHour:    tier.ChirpsPerHour,
+		CanEditChirps:    tier.CanEditChirps,
+		Badge:            tier.Badge,
+	})
+}
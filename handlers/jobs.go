@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/jobs"
+	"github.com/leonardoklaser/Chirpy/internal/mailer"
+)
+
+const (
+	JobSendEmail              = "email.send"
+	JobPurgeDeletedAccounts   = "accounts.purge"
+	JobPurgeDetachedMedia     = "media.purge"
+	JobPurgeDeadJobs          = "jobs.purge_dead"
+	JobPublishScheduledChirps = "chirps.publish_scheduled"
+	JobClosePolls             = "polls.close"
+	JobExpireSubscriptions    = "subscriptions.expire"
+	JobDeliverWebhooks        = "webhooks.deliver"
+	JobPurgeChirpEvents       = "chirps.purge_stream_events"
+	JobBuildDataExport        = "exports.build"
+)
+
+// deadJobTTL is how long dead jobs are kept for inspection.
+const deadJobTTL = 14 * 24 * time.Hour
+
+// sendEmail queues msg, so a slow or failing mail server only delays it and
+// sending is retried.
+func sendEmail(ctx context.Context, cfg *config.ApiConfig, msg mailer.Message) error {
+	_, err := cfg.Jobs.Enqueue(ctx, JobSendEmail, msg)
+	return err
+}
+
+// RegisterJobs registers the handlers of every job kind with runner.
+func RegisterJobs(runner *jobs.Runner, cfg *config.ApiConfig) {
+	jobs.Handle(runner, JobSendEmail, func(ctx context.Context, msg mailer.Message) error {
+		return cfg.Mailer.Send(ctx, msg)
+	})
+	jobs.Handle(runner, JobBuildDataExport, func(ctx context.Context, job dataExportJob) error {
+		return buildDataExport(ctx, cfg, job)
+	})
+	periodic := map[string]func(context.Context, *config.ApiConfig) error{
+		JobPurgeDeletedAccounts:   PurgeDeletedAccounts,
+		JobPurgeDetachedMedia:     PurgeDetachedMedia,
+		JobPurgeDeadJobs:          purgeDeadJobs,
+		JobPublishScheduledChirps: PublishScheduledChirps,
+		JobClosePolls:             ClosePolls,
+		JobExpireSubscriptions:    ExpireLapsedSubscriptions,
+		JobDeliverWebhooks:        DeliverWebhooks,
+		JobPurgeChirpEvents:       PurgeChirpEvents,
+	}
+	for kind, fn := range periodic {
+		jobs.Handle(runner, kind, func(ctx context.Context, _ struct{}) error {
+			return fn(ctx, cfg)
+		})
+	}
+}
+
+func purgeDeadJobs(ctx context.Context, cfg *config.ApiConfig) error {
+	return cfg.DB.DeleteDeadJobs(ctx, time.Now().UTC().Add(-deadJobTTL))
+}
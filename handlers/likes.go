@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// LikeChirp likes a chirp the caller can see. Liking a chirp twice is not an
+// error.
+func LikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id := r.PathValue("id")
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	chirp, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: uid, ViewerID: actorFromContext(r.Context())})
+	if err != nil || chirp.Status != "published" {
+		utils.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("Chirp with ID %s not found", id))
+		return
+	}
+
+	err = cfg.DB.CreateLike(r.Context(), database.CreateLikeParams{UserID: uuidUser, ChirpID: chirp.ID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to like chirp: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.DeleteLike(r.Context(), database.DeleteLikeParams{UserID: uuidUser, ChirpID: uid})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to unlike chirp: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp is not liked")
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
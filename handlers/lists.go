@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/profile"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	maxListNameLength        = 50
+	maxListDescriptionLength = 160
+	maxListsPerUser          = 100
+	maxListMembers           = 500
+)
+
+func toList(l database.List) models.List {
+	return models.List{
+		ID:          l.ID,
+		OwnerID:     l.OwnerID,
+		Name:        l.Name,
+		Description: l.Description,
+		Private:     l.IsPrivate,
+		CreatedAt:   l.CreatedAt,
+		UpdatedAt:   l.UpdatedAt,
+	}
+}
+
+type listRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+// decodeListRequest reads and validates the name, description and privacy
+// of a list.
+func decodeListRequest(w http.ResponseWriter, r *http.Request) (listRequest, bool) {
+	params := listRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return listRequest{}, false
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	params.Description = strings.TrimSpace(params.Description)
+	if params.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "List name is required")
+		return listRequest{}, false
+	}
+	if err := profile.ValidateText("name", params.Name, maxListNameLength); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return listRequest{}, false
+	}
+	if err := profile.ValidateText("description", params.Description, maxListDescriptionLength); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return listRequest{}, false
+	}
+	return params, true
+}
+
+// loadList reads the list in the path as viewer sees it. Private lists of
+// other users, and lists of users blocked either way, answer 404.
+func loadList(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, viewer uuid.NullUUID) (database.List, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve list Id : %v ", err))
+		return database.List{}, false
+	}
+	list, err := cfg.DB.GetList(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "List not found")
+		return database.List{}, false
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve list: %v", err))
+		return database.List{}, false
+	}
+	if viewer.Valid && viewer.UUID == list.OwnerID {
+		return list, true
+	}
+	if list.IsPrivate {
+		utils.RespondWithError(w, http.StatusNotFound, "List not found")
+		return database.List{}, false
+	}
+	if viewer.Valid {
+		blocked, err := cfg.DB.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{BlockerID: list.OwnerID, BlockedID: viewer.UUID})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve list: %v", err))
+			return database.List{}, false
+		}
+		if blocked {
+			utils.RespondWithError(w, http.StatusNotFound, "List not found")
+			return database.List{}, false
+		}
+	}
+	return list, true
+}
+
+// ownedList reads the list in the path, refusing callers who don't own it.
+func ownedList(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig) (database.List, bool) {
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return database.List{}, false
+	}
+	list, ok := loadList(w, r, cfg, uuid.NullUUID{UUID: uuidUser, Valid: true})
+	if !ok {
+		return database.List{}, false
+	}
+	if list.OwnerID != uuidUser {
+		utils.RespondWithError(w, http.StatusForbidden, "You can only change your own lists")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+func CreateList(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	params, ok := decodeListRequest(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := cfg.DB.CountListsByOwner(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list lists: %v", err))
+		return
+	}
+	if count >= maxListsPerUser {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("You can have at most %d lists", maxListsPerUser))
+		return
+	}
+
+	list, err := cfg.DB.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:     uuidUser,
+		Name:        params.Name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create list: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusCreated, toList(list))
+}
+
+func GetList(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	list, ok := loadList(w, r, cfg, actorFromContext(r.Context()))
+	if !ok {
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, toList(list))
+}
+
+// UpdateList replaces the name, description and privacy of one of the
+// caller's lists.
+func UpdateList(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	list, ok := ownedList(w, r, cfg)
+	if !ok {
+		return
+	}
+	params, ok := decodeListRequest(w, r)
+	if !ok {
+		return
+	}
+
+	list, err = cfg.DB.UpdateList(r.Context(), database.UpdateListParams{
+		ID:          list.ID,
+		Name:        params.Name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update list: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, toList(list))
+}
+
+func DeleteList(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	list, ok := ownedList(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DB.DeleteList(r.Context(), list.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to delete list: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+// respondLists writes a page of owner's lists, including the private ones
+// when includePrivate is set.
+func respondLists(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, owner uuid.UUID, includePrivate bool) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lists, err := cfg.DB.ListListsByOwner(r.Context(), database.ListListsByOwnerParams{
+		OwnerID:        owner,
+		IncludePrivate: includePrivate,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list lists: %v", err))
+		return
+	}
+
+	returnLists := []models.List{}
+	for _, val := range lists {
+		returnLists = append(returnLists, toList(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnLists)
+}
+
+func ListMyLists(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	respondLists(w, r, cfg, uuidUser, true)
+}
+
+// ListUserLists lists the public lists of the user in owner_id. Users see
+// their own private lists too.
+func ListUserLists(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	owner, err := uuid.Parse(r.URL.Query().Get("owner_id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve Owner Id : %v ", err))
+		return
+	}
+	if _, err := cfg.DB.GetUserById(r.Context(), owner); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	viewer := actorFromContext(r.Context())
+	if viewer.Valid && viewer.UUID != owner {
+		blocked, err := cfg.DB.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{BlockerID: owner, BlockedID: viewer.UUID})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list lists: %v", err))
+			return
+		}
+		if blocked {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+	}
+
+	respondLists(w, r, cfg, owner, viewer.Valid && viewer.UUID == owner)
+}
+
+func ListListMembers(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	list, ok := loadList(w, r, cfg, actorFromContext(r.Context()))
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	members, err := cfg.DB.ListListMembers(r.Context(), database.ListListMembersParams{ListID: list.ID, Limit: limit, Offset: offset})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list list members: %v", err))
+		return
+	}
+	if members == nil {
+		members = []uuid.UUID{}
+	}
+	utils.RespondWithJson(w, http.StatusOK, members)
+}
+
+// AddListMember adds a user to one of the caller's lists. Users who blocked
+// the caller, or were blocked by them, can't be added.
+func AddListMember(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	list, ok := ownedList(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	member, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+	if _, err := cfg.DB.GetUserById(r.Context(), member); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	blocked, err := cfg.DB.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{BlockerID: list.OwnerID, BlockedID: member})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to add list member: %v", err))
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You can't add this user")
+		return
+	}
+
+	count, err := cfg.DB.CountListMembers(r.Context(), list.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to add list member: %v", err))
+		return
+	}
+	if count >= maxListMembers {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A list can have at most %d members", maxListMembers))
+		return
+	}
+
+	err = cfg.DB.AddListMember(r.Context(), database.AddListMemberParams{ListID: list.ID, UserID: member})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to add list member: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func RemoveListMember(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	list, ok := ownedList(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	member, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.RemoveListMember(r.Context(), database.RemoveListMemberParams{ListID: list.ID, UserID: member})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to remove list member: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User is not on this list")
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+// ListListChirps is the list timeline: the published chirps of the list's
+// members, oldest first unless sort=desc, a page at a time.
+func ListListChirps(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	viewer := actorFromContext(r.Context())
+	list, ok := loadList(w, r, cfg, viewer)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.DB.ListChirpsForList(r.Context(), database.ListChirpsForListParams{
+		ListID:      list.ID,
+		ViewerID:    viewer,
+		NewestFirst: r.URL.Query().Get("sort") == "desc",
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list chirps: %v", err))
+		return
+	}
+
+	returnChirps := []models.Chirp{}
+	for _, val := range chirps {
+		returnChirps = append(returnChirps, toChirp(val))
+	}
+	if err := decorateChirps(r.Context(), cfg, viewer, returnChirps); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list chirp details: %v", err))
+		return
+	}
+	utils.RespondWithListJson(w, http.StatusOK, returnChirps)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/blobstore"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/media"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	// multipartOverhead leaves room for boundaries and part headers on top of
+	// the file itself.
+	multipartOverhead = 64 << 10
+	// detachedMediaTTL is how long an upload may wait to be attached to a
+	// chirp before it is removed.
+	detachedMediaTTL = 24 * time.Hour
+)
+
+func toAttachment(cfg *config.ApiConfig, attachment database.MediaAttachment) models.Attachment {
+	url := fmt.Sprintf("%s/api/media/%s", cfg.BaseURL, attachment.ID)
+	return models.Attachment{
+		ID:           attachment.ID,
+		ContentType:  attachment.ContentType,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+		URL:          url,
+		ThumbnailURL: url + "/thumbnail",
+	}
+}
+
+// withMedia fills in the attachments of chirps with a single query.
+func withMedia(ctx context.Context, cfg *config.ApiConfig, chirps []models.Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	attachments, err := cfg.DB.ListMediaForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byChirp := map[uuid.UUID][]models.Attachment{}
+	for _, val := range attachments {
+		byChirp[val.ChirpID.UUID] = append(byChirp[val.ChirpID.UUID], toAttachment(cfg, val))
+	}
+	for i := range chirps {
+		chirps[i].Media = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// readUpload returns the contents of the "file" part of a multipart upload,
+// reading at most limit+1 bytes so oversized files can be told apart.
+func readUpload(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing file field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		return io.ReadAll(io.LimitReader(part, limit+1))
+	}
+}
+
+func UploadMedia(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	data, err := readUpload(w, r, cfg.MediaLimits.MaxBytes)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, media.ErrTooLarge.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid upload: %v", err))
+		return
+	}
+
+	img, err := media.Process(data, cfg.MediaLimits)
+	if errors.Is(err, media.ErrTooLarge) {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if errors.Is(err, media.ErrUnsupportedType) {
+		utils.RespondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to process media: %v", err))
+		return
+	}
+
+	id := uuid.New()
+	params := database.CreateMediaAttachmentParams{
+		ID:            id,
+		UserID:        uuidUser,
+		ContentType:   img.ContentType,
+		SizeBytes:     int32(len(img.Data)),
+		Width:         int32(img.Width),
+		Height:        int32(img.Height),
+		StorageKey:    fmt.Sprintf("media/%s", id),
+		ThumbnailKey:  fmt.Sprintf("media/%s_thumb", id),
+		ThumbnailType: img.ThumbnailType,
+	}
+
+	err = cfg.Blobs.Put(r.Context(), params.StorageKey, bytes.NewReader(img.Data))
+	if err == nil {
+		err = cfg.Blobs.Put(r.Context(), params.ThumbnailKey, bytes.NewReader(img.Thumbnail))
+	}
+	var attachment database.MediaAttachment
+	if err == nil {
+		attachment, err = cfg.DB.CreateMediaAttachment(r.Context(), params)
+	}
+	if err != nil {
+		deleteMediaBlobs(context.Background(), cfg, database.MediaAttachment{StorageKey: params.StorageKey, ThumbnailKey: params.ThumbnailKey})
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to store media: %v", err))
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusCreated, toAttachment(cfg, attachment))
+}
+
+func GetMedia(w http.ResponseWriter, r *http.Request) {
+	serveMedia(w, r, false)
+}
+
+func GetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	serveMedia(w, r, true)
+}
+
+func serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve media Id : %v ", err))
+		return
+	}
+
+	attachment, err := cfg.DB.GetMediaAttachment(r.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	public, ok := mediaVisible(r, cfg, attachment)
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail {
+		key, contentType = attachment.ThumbnailKey, attachment.ThumbnailType
+	}
+
+	blob, err := cfg.Blobs.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to read media: %v", err))
+		return
+	}
+	defer blob.Close()
+
+	// Stored media never changes, so media of a public chirp may be cached
+	// for good. Anything else is only for the viewer, who may lose access.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if public {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age=60")
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, blob); err != nil {
+		log.Printf("Error sending media %s: %v", id, err)
+	}
+}
+
+// mediaVisible reports whether the viewer may see attachment, and whether it
+// belongs to a published chirp anyone can see. Media not yet attached to a
+// chirp is only visible to its uploader.
+func mediaVisible(r *http.Request, cfg *config.ApiConfig, attachment database.MediaAttachment) (public bool, ok bool) {
+	viewer := actorFromContext(r.Context())
+	if !attachment.ChirpID.Valid {
+		return false, viewer.Valid && viewer.UUID == attachment.UserID
+	}
+	chirp, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: attachment.ChirpID.UUID, ViewerID: viewer})
+	if err != nil {
+		return false, false
+	}
+	return !chirp.HiddenAt.Valid && chirp.Status == chirpStatusPublished, true
+}
+
+func deleteMediaBlobs(ctx context.Context, cfg *config.ApiConfig, attachment database.MediaAttachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if err := cfg.Blobs.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+// PurgeDetachedMedia removes uploads that were never attached to a chirp, or
+// whose chirp was deleted, once detachedMediaTTL has passed.
+func PurgeDetachedMedia(ctx context.Context, cfg *config.ApiConfig) error {
+	detached, err := cfg.DB.ListDetachedMedia(ctx, time.Now().Add(-detachedMediaTTL))
+	if err != nil {
+		return fmt.Errorf("error listing detached media: %w", err)
+	}
+	for _, attachment := range detached {
+		deleteMediaBlobs(ctx, cfg, attachment)
+		if err := cfg.DB.DeleteMediaAttachment(ctx, attachment.ID); err != nil {
+			return fmt.Errorf("error deleting media %s: %w", attachment.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,549 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/messaging"
+	"github.com/leonardoklaser/Chirpy/internal/pubsub"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// messagesChannel is the Postgres NOTIFY channel of new messages.
+const messagesChannel = "messages"
+
+func toMessage(m database.Message) models.Message {
+	return models.Message{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Body:           m.Body,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// toConversations adds the members and the latest message of each
+// conversation that viewerID can see.
+func toConversations(ctx context.Context, cfg *config.ApiConfig, viewerID uuid.UUID, rows []database.ListConversationsForUserRow) ([]models.Conversation, error) {
+	conversations := make([]models.Conversation, 0, len(rows))
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		conversations = append(conversations, models.Conversation{
+			ID:          row.ID,
+			IsGroup:     row.IsGroup,
+			MemberIDs:   []uuid.UUID{},
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			UnreadCount: row.UnreadCount,
+		})
+		ids = append(ids, row.ID)
+	}
+	if len(ids) == 0 {
+		return conversations, nil
+	}
+
+	members, err := cfg.DB.ListConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := cfg.DB.ListLatestMessages(ctx, database.ListLatestMessagesParams{ConversationIds: ids, ViewerID: viewerID})
+	if err != nil {
+		return nil, err
+	}
+	byID := map[uuid.UUID]*models.Conversation{}
+	for i := range conversations {
+		byID[conversations[i].ID] = &conversations[i]
+	}
+	for _, member := range members {
+		byID[member.ConversationID].MemberIDs = append(byID[member.ConversationID].MemberIDs, member.UserID)
+	}
+	for _, val := range latest {
+		message := toMessage(val)
+		byID[val.ConversationID].LastMessage = &message
+	}
+	return conversations, nil
+}
+
+// loadConversation reads the conversation in the path, answering 404 unless
+// the caller is a member.
+func loadConversation(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, userID uuid.UUID) (database.Conversation, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve conversation Id : %v ", err))
+		return database.Conversation{}, false
+	}
+	conversation, err := cfg.DB.GetConversationForMember(r.Context(), database.GetConversationForMemberParams{ID: id, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Conversation not found")
+		return database.Conversation{}, false
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve conversation: %v", err))
+		return database.Conversation{}, false
+	}
+	return conversation, true
+}
+
+// respondConversation writes a single conversation as the caller sees it.
+func respondConversation(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, status int, conversation database.Conversation) {
+	response, err := toConversations(r.Context(), cfg, actorFromContext(r.Context()).UUID, []database.ListConversationsForUserRow{{
+		ID:        conversation.ID,
+		CreatedBy: conversation.CreatedBy,
+		IsGroup:   conversation.IsGroup,
+		DirectKey: conversation.DirectKey,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+	}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve conversation: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, status, response[0])
+}
+
+// CreateConversation starts a conversation with one or more users. Starting
+// a one-to-one conversation that already exists returns the existing one.
+// Every recipient must accept messages from the caller under their
+// dm_policy, and nobody involved may have blocked another.
+func CreateConversation(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	type requestBody struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+
+	params := requestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return
+	}
+
+	seen := map[uuid.UUID]bool{uuidUser: true}
+	recipients := []uuid.UUID{}
+	for _, id := range params.MemberIDs {
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "A conversation needs at least one other member")
+		return
+	}
+	if len(recipients)+1 > messaging.MaxMembers {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A conversation can have at most %d members", messaging.MaxMembers))
+		return
+	}
+
+	permissions, err := cfg.DB.ListMessagingPermissions(r.Context(), database.ListMessagingPermissionsParams{SenderID: uuidUser, RecipientIds: recipients})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to check messaging settings: %v", err))
+		return
+	}
+	if len(permissions) != len(recipients) {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	for _, val := range permissions {
+		if val.Blocked || !messaging.CanMessage(val.DmPolicy, val.FollowsSender) {
+			utils.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("User %s doesn't accept messages from you", val.ID))
+			return
+		}
+	}
+
+	isGroup := len(recipients) > 1
+	if isGroup {
+		blocked, err := cfg.DB.HasBlocksAmong(r.Context(), recipients)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to check messaging settings: %v", err))
+			return
+		}
+		if blocked {
+			utils.RespondWithError(w, http.StatusForbidden, "Some of these users can't be in a conversation together")
+			return
+		}
+	}
+
+	directKey := sql.NullString{}
+	if !isGroup {
+		directKey = sql.NullString{String: messaging.DirectKey(uuidUser.String(), recipients[0].String()), Valid: true}
+		existing, err := cfg.DB.GetDirectConversation(r.Context(), directKey)
+		if err == nil {
+			respondConversation(w, r, cfg, http.StatusOK, existing)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve conversation: %v", err))
+			return
+		}
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create conversation: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	conversation, err := qtx.CreateConversation(r.Context(), database.CreateConversationParams{
+		CreatedBy: uuid.NullUUID{UUID: uuidUser, Valid: true},
+		IsGroup:   isGroup,
+		DirectKey: directKey,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// The other user started the same conversation in the meantime.
+		tx.Rollback()
+		existing, err := cfg.DB.GetDirectConversation(r.Context(), directKey)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve conversation: %v", err))
+			return
+		}
+		respondConversation(w, r, cfg, http.StatusOK, existing)
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create conversation: %v", err))
+		return
+	}
+	err = qtx.AddConversationMembers(r.Context(), database.AddConversationMembersParams{
+		ConversationID: conversation.ID,
+		UserIds:        append(recipients, uuidUser),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to add conversation members: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create conversation: %v", err))
+		return
+	}
+
+	respondConversation(w, r, cfg, http.StatusCreated, conversation)
+}
+
+// ListConversations lists the caller's conversations, most recently active
+// first.
+func ListConversations(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.DB.ListConversationsForUser(r.Context(), database.ListConversationsForUserParams{UserID: uuidUser, Limit: limit, Offset: offset})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list conversations: %v", err))
+		return
+	}
+	response, err := toConversations(r.Context(), cfg, uuidUser, rows)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list conversations: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+// ListMessages lists the messages of a conversation, newest first.
+func ListMessages(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	conversation, ok := loadConversation(w, r, cfg, uuidUser)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	messages, err := cfg.DB.ListMessages(r.Context(), database.ListMessagesParams{
+		ConversationID: conversation.ID,
+		ViewerID:       uuidUser,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list messages: %v", err))
+		return
+	}
+	response := []models.Message{}
+	for _, val := range messages {
+		response = append(response, toMessage(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+// SendMessage posts a message to a conversation. Bodies go through the
+// chirp profanity filter, under the message length limit. A one-to-one
+// conversation is closed once either member blocks the other.
+func SendMessage(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	type requestBody struct {
+		Body string `json:"body"`
+	}
+
+	conversation, ok := loadConversation(w, r, cfg, uuidUser)
+	if !ok {
+		return
+	}
+
+	params := requestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return
+	}
+	if err := messaging.ValidateBody(params.Body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !conversation.IsGroup {
+		members, err := cfg.DB.ListConversationMembers(r.Context(), []uuid.UUID{conversation.ID})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve conversation members: %v", err))
+			return
+		}
+		for _, member := range members {
+			if member.UserID == uuidUser {
+				continue
+			}
+			blocked, err := cfg.DB.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{BlockerID: uuidUser, BlockedID: member.UserID})
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send message: %v", err))
+				return
+			}
+			if blocked {
+				utils.RespondWithError(w, http.StatusForbidden, "You can't message this user")
+				return
+			}
+		}
+	}
+
+	body, err := cleanChirpBody(params.Body)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error to format profane words")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send message: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	message, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{ConversationID: conversation.ID, SenderID: uuidUser, Body: body})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send message: %v", err))
+		return
+	}
+	if err := qtx.TouchConversation(r.Context(), conversation.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send message: %v", err))
+		return
+	}
+	err = qtx.MarkConversationRead(r.Context(), database.MarkConversationReadParams{ConversationID: conversation.ID, UserID: uuidUser})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send message: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send message: %v", err))
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusCreated, toMessage(message))
+}
+
+// MarkConversationRead marks every message of a conversation as read by the
+// caller.
+func MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	conversation, ok := loadConversation(w, r, cfg, uuidUser)
+	if !ok {
+		return
+	}
+	err = cfg.DB.MarkConversationRead(r.Context(), database.MarkConversationReadParams{ConversationID: conversation.ID, UserID: uuidUser})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to mark conversation read: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+// StreamMessages pushes the messages of all the caller's conversations as
+// server-sent events, including the caller's own so other devices stay in
+// sync.
+func StreamMessages(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	messages, cancel := cfg.Messages.Subscribe(uuidUser)
+	defer cancel()
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+	streamEvents(w, r, flusher, messages, "message", func(m models.Message) string { return m.ID.String() })
+}
+
+// RelayMessages pushes the messages sent through any instance to the
+// streams of the members connected to this one, until ctx is done. Messages
+// sent while the connection was down are not replayed; clients catch up
+// with ListMessages.
+func RelayMessages(ctx context.Context, cfg *config.ApiConfig) error {
+	return pubsub.Listen(ctx, cfg.DatabaseURL, messagesChannel, func(payload string) {
+		if payload == "" {
+			return
+		}
+		id, err := uuid.Parse(payload)
+		if err != nil {
+			log.Printf("Invalid message notification %q", payload)
+			return
+		}
+		message, err := cfg.DB.GetMessage(ctx, id)
+		if err != nil {
+			log.Printf("Error loading message %s: %v", id, err)
+			return
+		}
+		members, err := cfg.DB.ListConversationMembers(ctx, []uuid.UUID{message.ConversationID})
+		if err != nil {
+			log.Printf("Error loading members of conversation %s: %v", message.ConversationID, err)
+			return
+		}
+		for _, member := range members {
+			if member.UserID != message.SenderID {
+				// Like ListMessages, leave out senders blocked either way.
+				blocked, err := cfg.DB.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{BlockerID: member.UserID, BlockedID: message.SenderID})
+				if err != nil || blocked {
+					continue
+				}
+			}
+			cfg.Messages.Publish(member.UserID, toMessage(message))
+		}
+	})
+}
+
+func GetMessagingSettings(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	policy, err := cfg.DB.GetMessagingPolicy(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve messaging settings: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, models.MessagingSettings{DMPolicy: policy})
+}
+
+// UpdateMessagingSettings sets who may start a conversation with the
+// caller: everyone, the users they follow, or nobody. Existing
+// conversations are not affected.
+func UpdateMessagingSettings(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	params := models.MessagingSettings{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return
+	}
+	if !messaging.IsValidPolicy(params.DMPolicy) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("dm_policy must be %q, %q or %q", messaging.PolicyEveryone, messaging.PolicyFollowing, messaging.PolicyNobody))
+		return
+	}
+
+	err = cfg.DB.UpsertMessagingPolicy(r.Context(), database.UpsertMessagingPolicyParams{UserID: uuidUser, DmPolicy: params.DMPolicy})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update messaging settings: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, params)
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/notification"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+func toNotification(n database.Notification) models.Notification {
+	return models.Notification{
+		ID:        n.ID,
+		Type:      n.Type,
+		ActorID:   nullUUIDPtr(n.ActorID),
+		ChirpID:   nullUUIDPtr(n.ChirpID),
+		CreatedAt: n.CreatedAt,
+		ReadAt:    nullTimePtr(n.ReadAt),
+	}
+}
+
+// notify creates a notification for recipient and pushes it to their open
+// streams. Nobody is notified of their own actions, and the recipient's
+// preferences, blocks and mutes are respected. A failure is logged rather
+// than failing the action behind the notification.
+func notify(ctx context.Context, cfg *config.ApiConfig, recipient uuid.UUID, notificationType string, actor, chirpID uuid.NullUUID) {
+	if actor.Valid && actor.UUID == recipient {
+		return
+	}
+	n, err := cfg.DB.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  recipient,
+		Type:    notificationType,
+		ActorID: actor,
+		ChirpID: chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating %s notification for user %s: %v", notificationType, recipient, err)
+		return
+	}
+	cfg.Notifications.Publish(recipient, toNotification(n))
+}
+
+// notifyChirpPublished notifies the author of the chirp a published chirp
+// replies to, and the users it mentions. A user who is both is only
+// notified of the reply.
+func notifyChirpPublished(ctx context.Context, cfg *config.ApiConfig, chirp database.Chirp) {
+	actor := uuid.NullUUID{UUID: chirp.UserID, Valid: true}
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+
+	notified := map[uuid.UUID]bool{}
+	if chirp.ReplyToID.Valid {
+		parent, err := cfg.DB.GetChirpById(ctx, chirp.ReplyToID.UUID)
+		if err == nil {
+			notify(ctx, cfg, parent.UserID, notification.TypeReply, actor, chirpID)
+			notified[parent.UserID] = true
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error loading the chirp %s replies to: %v", chirp.ID, err)
+		}
+	}
+
+	handles := notification.ExtractMentions(chirp.Body)
+	if len(handles) == 0 {
+		return
+	}
+	mentioned, err := cfg.DB.ListUserIdsByHandles(ctx, handles)
+	if err != nil {
+		log.Printf("Error resolving mentions of chirp %s: %v", chirp.ID, err)
+		return
+	}
+	for _, userID := range mentioned {
+		if !notified[userID] {
+			notify(ctx, cfg, userID, notification.TypeMention, actor, chirpID)
+		}
+	}
+}
+
+func ListNotifications(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notifications, err := cfg.DB.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     uuidUser,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve notifications: %v", err))
+		return
+	}
+	unread, err := cfg.DB.CountUnreadNotifications(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to count notifications: %v", err))
+		return
+	}
+
+	response := models.NotificationList{UnreadCount: unread, Notifications: []models.Notification{}}
+	for _, val := range notifications {
+		response.Notifications = append(response.Notifications, toNotification(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+// MarkNotificationsRead marks the given notifications as read, or all of
+// them when no ids are sent.
+func MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	type requestBody struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	params := requestBody{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+			return
+		}
+	}
+	if params.IDs == nil {
+		params.IDs = []uuid.UUID{}
+	}
+
+	_, err = cfg.DB.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{UserID: uuidUser, Ids: params.IDs})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to mark notifications read: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+// notificationPreferences returns whether each notification type is enabled
+// for the user. Types without a stored preference are enabled.
+func notificationPreferences(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) (map[string]bool, error) {
+	stored, err := cfg.DB.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := map[string]bool{}
+	for _, t := range notification.Types {
+		preferences[t] = true
+	}
+	for _, val := range stored {
+		if notification.IsKnownType(val.Type) {
+			preferences[val.Type] = val.Enabled
+		}
+	}
+	return preferences, nil
+}
+
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	preferences, err := notificationPreferences(r.Context(), cfg, uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve notification preferences: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences turns notification types on or off. Types
+// missing from the body keep their current setting.
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	params := map[string]bool{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Input")
+		return
+	}
+	for t := range params {
+		if !notification.IsKnownType(t) {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown notification type %q", t))
+			return
+		}
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update notification preferences: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+	for t, enabled := range params {
+		err = qtx.UpsertNotificationPreference(r.Context(), database.UpsertNotificationPreferenceParams{UserID: uuidUser, Type: t, Enabled: enabled})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update notification preferences: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update notification preferences: %v", err))
+		return
+	}
+
+	preferences, err := notificationPreferences(r.Context(), cfg, uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve notification preferences: %v", err))
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, preferences)
+}
+
+// StreamNotifications pushes the caller's new notifications as server-sent
+// events until the client disconnects or the server shuts down. Clients
+// catch up on anything missed while disconnected with ListNotifications.
+func StreamNotifications(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	notifications, cancel := cfg.Notifications.Subscribe(uuidUser)
+	defer cancel()
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+	streamEvents(w, r, flusher, notifications, "notification", func(n models.Notification) string { return n.ID.String() })
+}
@@ -0,0 +1,516 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	oauthCodeTTL         = 10 * time.Minute
+	oauthAccessTokenTTL  = time.Hour
+	oauthRefreshTokenTTL = 60 * 24 * time.Hour
+)
+
+//go:embed templates/consent.html
+var templateFS embed.FS
+
+var consentTemplate = template.Must(template.ParseFS(templateFS, "templates/consent.html"))
+
+type consentPage struct {
+	ClientName    string
+	ClientID      string
+	RedirectURI   string
+	Scope         string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+	Email         string
+	Error         string
+}
+
+// authorizeRequest holds the validated parameters of an authorization
+// request, shared by the consent page and the form it submits.
+type authorizeRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+func randomToken(prefix string, size int) (string, error) {
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(key), nil
+}
+
+func respondOAuthError(w http.ResponseWriter, statusCode int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJson(w, statusCode, map[string]string{"error": code, "error_description": description})
+}
+
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	return u.Scheme == "https" || (u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1"))
+}
+
+func parseAuthorizeRequest(r *http.Request, values url.Values, cfg *config.ApiConfig) (authorizeRequest, error) {
+	req := authorizeRequest{
+		redirectURI:   values.Get("redirect_uri"),
+		scopes:        strings.Fields(values.Get("scope")),
+		state:         values.Get("state"),
+		codeChallenge: values.Get("code_challenge"),
+	}
+
+	client, err := cfg.DB.GetOAuthClient(r.Context(), values.Get("client_id"))
+	if err != nil {
+		return req, errors.New("Unknown client")
+	}
+	req.client = client
+
+	if !slices.Contains(client.RedirectUris, req.redirectURI) {
+		return req, errors.New("Redirect URI is not registered for this client")
+	}
+	if values.Get("response_type") != "code" {
+		return req, errors.New("Only the authorization code flow is supported")
+	}
+	if req.codeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		return req, errors.New("PKCE with code_challenge_method S256 is required")
+	}
+	if len(req.scopes) == 0 {
+		return req, errors.New("At least one scope is required")
+	}
+	for _, scope := range req.scopes {
+		if !auth.IsKnownScope(scope) {
+			return req, fmt.Errorf("Unknown scope %q", scope)
+		}
+	}
+	return req, nil
+}
+
+func renderConsentPage(w http.ResponseWriter, statusCode int, req authorizeRequest, email, errorMessage string) {
+	page := consentPage{
+		ClientName:    req.client.Name,
+		ClientID:      req.client.ID,
+		RedirectURI:   req.redirectURI,
+		Scope:         strings.Join(req.scopes, " "),
+		Scopes:        req.scopes,
+		State:         req.state,
+		CodeChallenge: req.codeChallenge,
+		Email:         email,
+		Error:         errorMessage,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(statusCode)
+	if err := consentTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering consent page: %v", err)
+	}
+}
+
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// authenticateOAuthClient checks the client credentials of a token or
+// revocation request. Public clients (registered without a secret) only
+// need their client_id.
+func authenticateOAuthClient(r *http.Request, cfg *config.ApiConfig) (database.OauthClient, bool) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := cfg.DB.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return client, false
+	}
+	if !client.SecretHash.Valid {
+		return client, true
+	}
+	return client, subtle.ConstantTimeCompare([]byte(client.SecretHash.String), []byte(auth.HashToken(secret))) == 1
+}
+
+func issueOAuthTokens(r *http.Request, cfg *config.ApiConfig, clientID string, userID uuid.UUID, scopes []string) (models.OAuthToken, error) {
+	accessToken, err := auth.MakeScopedJWT(userID, clientID, scopes, cfg.SecretKey, oauthAccessTokenTTL)
+	if err != nil {
+		return models.OAuthToken{}, err
+	}
+
+	refreshToken, _ := auth.MakeRefreshToken()
+	_, err = cfg.DB.CreateOAuthRefreshToken(r.Context(), database.CreateOAuthRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(oauthRefreshTokenTTL), Valid: true},
+		ClientID:  sql.NullString{String: clientID, Valid: true},
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return models.OAuthToken{}, err
+	}
+
+	return models.OAuthToken{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+func RegisterOAuthClient(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if params.Name == "" || len(params.RedirectURIs) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name and at least one redirect URI are required")
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if !validRedirectURI(uri) {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid redirect URI %q, it must use https", uri))
+			return
+		}
+	}
+
+	clientID, err := randomToken("chirpy_client_", 16)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating client id")
+		return
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if params.Confidential {
+		secret, err = randomToken("chirpy_secret_", 32)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error generating client secret")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.DB.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           clientID,
+		SecretHash:   secretHash,
+		Name:         params.Name,
+		RedirectUris: params.RedirectURIs,
+		OwnerID:      uuidUser,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to register client: %v", err))
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusCreated, models.OAuthClient{
+		ID:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		OwnerID:      client.OwnerID,
+		CreatedAt:    client.CreatedAt,
+		Secret:       secret,
+	})
+}
+
+func OAuthAuthorizePage(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	req, err := parseAuthorizeRequest(r, r.URL.Query(), cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	renderConsentPage(w, http.StatusOK, req, "", "")
+}
+
+func OAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	// Until the redirect URI is validated, errors are shown here instead of
+	// being sent back to the client.
+	req, err := parseAuthorizeRequest(r, r.PostForm, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithParams(w, r, req.redirectURI, url.Values{"error": {"access_denied"}, "state": {req.state}})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	user, loginErr := checkCredentials(r.Context(), cfg, email, r.PostForm.Get("password"))
+	auditLogin(r, cfg, user.ID, email, loginErr, map[string]any{"client_id": req.client.ID})
+	if loginErr != nil {
+		renderConsentPage(w, loginErr.status, req, email, loginErr.message)
+		return
+	}
+
+	err = cfg.DB.UpsertOAuthConsent(r.Context(), database.UpsertOAuthConsentParams{UserID: user.ID, ClientID: req.client.ID, Scopes: req.scopes})
+	if err != nil {
+		renderConsentPage(w, http.StatusInternalServerError, req, email, "Could not record your consent, please try again")
+		return
+	}
+
+	code, err := randomToken("", 32)
+	if err == nil {
+		err = cfg.DB.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+			CodeHash:      auth.HashToken(code),
+			ClientID:      req.client.ID,
+			UserID:        user.ID,
+			RedirectUri:   req.redirectURI,
+			Scopes:        req.scopes,
+			CodeChallenge: req.codeChallenge,
+			ExpiresAt:     time.Now().Add(oauthCodeTTL),
+		})
+	}
+	if err != nil {
+		redirectWithParams(w, r, req.redirectURI, url.Values{"error": {"server_error"}, "state": {req.state}})
+		return
+	}
+
+	redirectWithParams(w, r, req.redirectURI, url.Values{"code": {code}, "state": {req.state}})
+}
+
+func OAuthToken(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body")
+		return
+	}
+
+	client, ok := authenticateOAuthClient(r, cfg)
+	if !ok {
+		respondOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+	clientID := sql.NullString{String: client.ID, Valid: true}
+
+	var userID uuid.UUID
+	var scopes []string
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.DB.ConsumeOAuthAuthorizationCode(r.Context(), database.ConsumeOAuthAuthorizationCodeParams{
+			CodeHash: auth.HashToken(r.PostForm.Get("code")),
+			ClientID: client.ID,
+		})
+		if err != nil {
+			respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code is invalid, expired or already used")
+			return
+		}
+		if code.RedirectUri != r.PostForm.Get("redirect_uri") {
+			respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "Redirect URI does not match the authorization request")
+			return
+		}
+		if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+			return
+		}
+		userID, scopes = code.UserID, code.Scopes
+
+	case "refresh_token":
+		token := r.PostForm.Get("refresh_token")
+		// Refresh tokens are rotated on every use.
+		refresh, err := cfg.DB.RotateOAuthRefreshToken(r.Context(), database.RotateOAuthRefreshTokenParams{Token: token, ClientID: clientID})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "Refresh token is invalid")
+			return
+		}
+		if err != nil {
+			respondOAuthError(w, http.StatusInternalServerError, "server_error", "Error rotating refresh token")
+			return
+		}
+		userID, scopes = refresh.UserID, refresh.Scopes
+		recordAudit(r, cfg, audit.ActionTokenRefreshed, uuid.NullUUID{UUID: userID, Valid: true}, "user", userID.String(), map[string]any{"kind": "oauth_refresh_token", "client_id": client.ID})
+
+	default:
+		respondOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Supported grant types are authorization_code and refresh_token")
+		return
+	}
+
+	response, err := issueOAuthTokens(r, cfg, client.ID, userID, scopes)
+	if err != nil {
+		respondOAuthError(w, http.StatusInternalServerError, "server_error", "Error issuing tokens")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+// OAuthRevoke revokes a refresh token (RFC 7009). Access tokens are
+// short-lived JWTs and simply expire.
+func OAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body")
+		return
+	}
+
+	client, ok := authenticateOAuthClient(r, cfg)
+	if !ok {
+		respondOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	result, err := cfg.DB.RevokeOAuthRefreshToken(r.Context(), database.RevokeOAuthRefreshTokenParams{
+		Token:    r.PostForm.Get("token"),
+		ClientID: sql.NullString{String: client.ID, Valid: true},
+	})
+	if err != nil {
+		respondOAuthError(w, http.StatusInternalServerError, "server_error", "Error revoking token")
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		recordAudit(r, cfg, audit.ActionTokenRevoked, uuid.NullUUID{}, "oauth_client", client.ID, map[string]any{"kind": "oauth_refresh_token"})
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func ListOAuthConsents(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	consents, err := cfg.DB.ListOAuthConsents(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list consents: %v", err))
+		return
+	}
+
+	returnConsents := []models.OAuthConsent{}
+	for _, val := range consents {
+		returnConsents = append(returnConsents, models.OAuthConsent{
+			ClientID:   val.ClientID,
+			ClientName: val.ClientName,
+			Scopes:     val.Scopes,
+			CreatedAt:  val.CreatedAt,
+			UpdatedAt:  val.UpdatedAt,
+		})
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnConsents)
+}
+
+func RevokeOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	clientID := r.PathValue("clientID")
+	result, err := cfg.DB.DeleteOAuthConsent(r.Context(), database.DeleteOAuthConsentParams{UserID: uuidUser, ClientID: clientID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to revoke consent: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Consent not found")
+		return
+	}
+
+	err = cfg.DB.RevokeOAuthRefreshTokensForClient(r.Context(), database.RevokeOAuthRefreshTokensForClientParams{
+		UserID:   uuidUser,
+		ClientID: sql.NullString{String: clientID, Valid: true},
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to revoke client tokens: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
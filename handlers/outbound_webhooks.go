@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusDelivered = "delivered"
+	deliveryStatusDead      = "dead"
+
+	maxWebhookEndpoints = 10
+	// webhookBatchSize is how many deliveries a dispatcher run claims.
+	webhookBatchSize = 20
+)
+
+// queueEvent queues an outbound webhook event about subject. Pass the
+// caller's transaction as q so the event is only delivered if the change
+// behind it is committed.
+func queueEvent(ctx context.Context, q *database.Queries, eventType string, subject uuid.UUID, data any) error {
+	payload, err := json.Marshal(outbound.NewEvent(eventType, data))
+	if err != nil {
+		return err
+	}
+	return q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: eventType,
+		Payload:   string(payload),
+		SubjectID: uuid.NullUUID{UUID: subject, Valid: true},
+	})
+}
+
+// notifyIntegrators queues an event outside of a transaction. A failure is
+// logged rather than failing a change that already happened.
+func notifyIntegrators(ctx context.Context, cfg *config.ApiConfig, eventType string, subject uuid.UUID, data any) {
+	if err := queueEvent(ctx, cfg.DB, eventType, subject, data); err != nil {
+		log.Printf("Error queueing %s webhook: %v", eventType, err)
+	}
+}
+
+// DeliverWebhooks sends the deliveries that are due. Failed deliveries are
+// retried with exponential backoff and marked dead after
+// outbound.MaxAttempts.
+func DeliverWebhooks(ctx context.Context, cfg *config.ApiConfig) error {
+	deliveries, err := cfg.DB.ClaimWebhookDeliveries(ctx, webhookBatchSize)
+	if err != nil {
+		return fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	for _, delivery := range deliveries {
+		code, err := cfg.WebhookSender.Deliver(ctx, delivery.Url, delivery.Secret, delivery.EventType, delivery.ID, []byte(delivery.Payload))
+		if err == nil {
+			err = cfg.DB.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{ID: delivery.ID, LastStatusCode: int32(code)})
+			if err != nil {
+				log.Printf("Error updating webhook delivery %s: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		status := deliveryStatusPending
+		if delivery.Attempts >= outbound.MaxAttempts {
+			status = deliveryStatusDead
+		}
+		err = cfg.DB.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+			ID:             delivery.ID,
+			Status:         status,
+			NextAttemptAt:  time.Now().UTC().Add(outbound.Backoff(int(delivery.Attempts))),
+			LastStatusCode: int32(code),
+			LastError:      err.Error(),
+		})
+		if err != nil {
+			log.Printf("Error updating webhook delivery %s: %v", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+func toWebhookEndpoint(endpoint database.WebhookEndpoint) models.WebhookEndpoint {
+	return models.WebhookEndpoint{
+		ID:        endpoint.ID,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+func toWebhookDelivery(delivery database.WebhookDelivery) models.WebhookDelivery {
+	response := models.WebhookDelivery{
+		ID:             delivery.ID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    nullTimePtr(delivery.DeliveredAt),
+	}
+	if delivery.Status == deliveryStatusPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return response
+}
+
+// webhookOwner returns the owner of the endpoints a request manages: the
+// authenticated user, or no owner on the admin routes.
+func webhookOwner(w http.ResponseWriter, r *http.Request, admin bool) (uuid.NullUUID, bool) {
+	if admin {
+		return uuid.NullUUID{}, true
+	}
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return uuid.NullUUID{}, false
+	}
+	return uuid.NullUUID{UUID: uuidUser, Valid: true}, true
+}
+
+// ownedWebhookEndpoint loads the endpoint in the path, answering 404 when it
+// belongs to someone else.
+func ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, owner uuid.NullUUID) (database.WebhookEndpoint, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve webhook Id : %v ", err))
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.DB.GetWebhookEndpoint(r.Context(), id)
+	if err != nil || endpoint.OwnerID != owner {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook not found")
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	createWebhookEndpoint(w, r, false)
+}
+
+func AdminCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	createWebhookEndpoint(w, r, true)
+}
+
+func createWebhookEndpoint(w http.ResponseWriter, r *http.Request, admin bool) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	owner, ok := webhookOwner(w, r, admin)
+	if !ok {
+		return
+	}
+
+	type requestBody struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := outbound.ValidateURL(params.URL, cfg.WebhookAllowInsecure); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(params.Events) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "At least one event is required")
+		return
+	}
+	for _, event := range params.Events {
+		if !outbound.IsKnownEvent(event) {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event %q", event))
+			return
+		}
+	}
+
+	existing, err := cfg.DB.ListWebhookEndpoints(r.Context(), owner)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list webhooks: %v", err))
+		return
+	}
+	if len(existing) >= maxWebhookEndpoints {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("You can register at most %d webhooks", maxWebhookEndpoints))
+		return
+	}
+
+	secret, err := outbound.MakeSecret()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating webhook secret")
+		return
+	}
+
+	endpoint, err := cfg.DB.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		OwnerID: owner,
+		Url:     params.URL,
+		Secret:  secret,
+		Events:  params.Events,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create webhook: %v", err))
+		return
+	}
+	if admin {
+		recordAdminAction(r, cfg, "webhook.created", "webhook", endpoint.ID.String(), map[string]any{"url": endpoint.Url, "events": endpoint.Events})
+	}
+
+	// The secret is only ever returned here.
+	response := toWebhookEndpoint(endpoint)
+	response.Secret = secret
+	utils.RespondWithJson(w, http.StatusCreated, response)
+}
+
+func ListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	listWebhookEndpoints(w, r, false)
+}
+
+func AdminListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	listWebhookEndpoints(w, r, true)
+}
+
+func listWebhookEndpoints(w http.ResponseWriter, r *http.Request, admin bool) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	owner, ok := webhookOwner(w, r, admin)
+	if !ok {
+		return
+	}
+
+	endpoints, err := cfg.DB.ListWebhookEndpoints(r.Context(), owner)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list webhooks: %v", err))
+		return
+	}
+
+	response := []models.WebhookEndpoint{}
+	for _, val := range endpoints {
+		response = append(response, toWebhookEndpoint(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+func DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	deleteWebhookEndpoint(w, r, false)
+}
+
+func AdminDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	deleteWebhookEndpoint(w, r, true)
+}
+
+func deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, admin bool) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	owner, ok := webhookOwner(w, r, admin)
+	if !ok {
+		return
+	}
+	endpoint, ok := ownedWebhookEndpoint(w, r, cfg, owner)
+	if !ok {
+		return
+	}
+
+	// Pending deliveries go with the endpoint.
+	if err := cfg.DB.DeleteWebhookEndpoint(r.Context(), endpoint.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to delete webhook: %v", err))
+		return
+	}
+	if admin {
+		recordAdminAction(r, cfg, "webhook.deleted", "webhook", endpoint.ID.String(), map[string]any{"url": endpoint.Url})
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+// ListWebhookDeliveries is the delivery log of an endpoint, newest first.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	listWebhookDeliveries(w, r, false)
+}
+
+func AdminListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	listWebhookDeliveries(w, r, true)
+}
+
+func listWebhookDeliveries(w http.ResponseWriter, r *http.Request, admin bool) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	owner, ok := webhookOwner(w, r, admin)
+	if !ok {
+		return
+	}
+	endpoint, ok := ownedWebhookEndpoint(w, r, cfg, owner)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := cfg.DB.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{EndpointID: endpoint.ID, Limit: limit, Offset: offset})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list webhook deliveries: %v", err))
+		return
+	}
+
+	response := []models.WebhookDelivery{}
+	for _, val := range deliveries {
+		response = append(response, toWebhookDelivery(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
+
+// ReplayWebhookDelivery queues a delivery again with a fresh set of attempts,
+// whatever its current state.
+func ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	replayWebhookDelivery(w, r, false)
+}
+
+func AdminReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	replayWebhookDelivery(w, r, true)
+}
+
+func replayWebhookDelivery(w http.ResponseWriter, r *http.Request, admin bool) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	owner, ok := webhookOwner(w, r, admin)
+	if !ok {
+		return
+	}
+	endpoint, ok := ownedWebhookEndpoint(w, r, cfg, owner)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve delivery Id : %v ", err))
+		return
+	}
+
+	delivery, err := cfg.DB.ReplayWebhookDelivery(r.Context(), database.ReplayWebhookDeliveryParams{ID: deliveryID, EndpointID: endpoint.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to replay delivery: %v", err))
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusAccepted, toWebhookDelivery(delivery))
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func toPersonalAccessToken(pat database.PersonalAccessToken) models.PersonalAccessToken {
+	return models.PersonalAccessToken{
+		ID:         pat.ID,
+		Name:       pat.Name,
+		Scopes:     pat.Scopes,
+		CreatedAt:  pat.CreatedAt,
+		LastUsedAt: nullTimePtr(pat.LastUsedAt),
+		ExpiresAt:  nullTimePtr(pat.ExpiresAt),
+		RevokedAt:  nullTimePtr(pat.RevokedAt),
+	}
+}
+
+func CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if params.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token name is required")
+		return
+	}
+	if len(params.Scopes) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.IsKnownScope(scope) {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope))
+			return
+		}
+	}
+
+	var expiresAt sql.NullTime
+	if params.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(time.Duration(params.ExpiresInDays) * 24 * time.Hour), Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	pat, err := cfg.DB.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    uuidUser,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create token: %v", err))
+		return
+	}
+
+	// The plaintext token is only ever returned here.
+	response := toPersonalAccessToken(pat)
+	response.Token = token
+	utils.RespondWithJson(w, http.StatusCreated, response)
+}
+
+func ListPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	tokens, err := cfg.DB.ListPersonalAccessTokens(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list tokens: %v", err))
+		return
+	}
+
+	returnTokens := []models.PersonalAccessToken{}
+	for _, val := range tokens {
+		returnTokens = append(returnTokens, toPersonalAccessToken(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnTokens)
+}
+
+func RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve token Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{ID: id, UserID: uuidUser})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to revoke token: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Token not found")
+		return
+	}
+
+	recordAudit(r, cfg, audit.ActionTokenRevoked, uuid.NullUUID{UUID: uuidUser, Valid: true}, "personal_access_token", id.String(), map[string]any{"kind": "personal_access_token"})
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/mailer"
+	"github.com/leonardoklaser/Chirpy/internal/notification"
+	"github.com/leonardoklaser/Chirpy/internal/poll"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// withPolls fills in the polls of chirps as seen by viewer.
+func withPolls(ctx context.Context, cfg *config.ApiConfig, viewer uuid.NullUUID, chirps []models.Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	polls, err := cfg.DB.ListPollsForChirps(ctx, ids)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+	options, err := cfg.DB.ListPollOptionsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	voted := map[uuid.UUID]int32{}
+	if viewer.Valid {
+		votes, err := cfg.DB.ListPollVotesForUser(ctx, database.ListPollVotesForUserParams{UserID: viewer.UUID, ChirpIds: ids})
+		if err != nil {
+			return err
+		}
+		for _, vote := range votes {
+			voted[vote.ChirpID] = vote.Position
+		}
+	}
+
+	now := time.Now()
+	byChirp := map[uuid.UUID]*models.Poll{}
+	for _, val := range polls {
+		byChirp[val.ChirpID] = &models.Poll{
+			ClosesAt: val.ClosesAt,
+			Closed:   poll.IsClosed(val.ClosesAt, val.ClosedAt.Valid, now),
+		}
+		if position, ok := voted[val.ChirpID]; ok {
+			byChirp[val.ChirpID].VotedOption = &position
+		}
+	}
+	for _, val := range options {
+		p := byChirp[val.ChirpID]
+		if p == nil {
+			continue
+		}
+		option := models.PollOption{Label: val.Label}
+		if p.Closed || p.VotedOption != nil {
+			votes := val.Votes
+			option.Votes = &votes
+			total := votes
+			if p.TotalVotes != nil {
+				total += *p.TotalVotes
+			}
+			p.TotalVotes = &total
+		}
+		p.Options = append(p.Options, option)
+	}
+	for i := range chirps {
+		chirps[i].Poll = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+func VotePoll(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	type requestBody struct {
+		Option *int32 `json:"option"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil || params.Option == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	chirp, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: id, ViewerID: uuid.NullUUID{UUID: uuidUser, Valid: true}})
+	if err != nil || chirp.Status != chirpStatusPublished {
+		utils.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("Chirp with ID %s not found", id))
+		return
+	}
+
+	p, err := cfg.DB.GetPoll(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp has no poll")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve poll: %v", err))
+		return
+	}
+	if poll.IsClosed(p.ClosesAt, p.ClosedAt.Valid, time.Now()) {
+		utils.RespondWithError(w, http.StatusConflict, "Poll is closed")
+		return
+	}
+
+	options, err := cfg.DB.ListPollOptionsForChirps(r.Context(), []uuid.UUID{id})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve poll: %v", err))
+		return
+	}
+	if *params.Option < 0 || int(*params.Option) >= len(options) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Unknown poll option")
+		return
+	}
+
+	result, err := cfg.DB.CastPollVote(r.Context(), database.CastPollVoteParams{UserID: uuidUser, Position: *params.Option, ChirpID: id})
+	if database.IsUniqueViolation(err) {
+		utils.RespondWithError(w, http.StatusConflict, "You already voted in this poll")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to vote: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Poll is closed")
+		return
+	}
+
+	respondChirp(w, r, cfg, http.StatusOK, chirp)
+}
+
+// ClosePolls marks expired polls as closed and emails each author the final
+// results.
+func ClosePolls(ctx context.Context, cfg *config.ApiConfig) error {
+	closed, err := cfg.DB.CloseDuePolls(ctx)
+	if err != nil {
+		return fmt.Errorf("error closing polls: %w", err)
+	}
+	for _, p := range closed {
+		if err := notifyPollClosed(ctx, cfg, p.ChirpID); err != nil {
+			log.Printf("Error notifying author of poll %s: %v", p.ChirpID, err)
+		}
+	}
+	return nil
+}
+
+func notifyPollClosed(ctx context.Context, cfg *config.ApiConfig, chirpID uuid.UUID) error {
+	chirp, err := cfg.DB.GetChirpById(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	notify(ctx, cfg, chirp.UserID, notification.TypePollClosed, uuid.NullUUID{}, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+
+	author, err := cfg.DB.GetUserById(ctx, chirp.UserID)
+	if err != nil {
+		return err
+	}
+	options, err := cfg.DB.ListPollOptionsForChirps(ctx, []uuid.UUID{chirpID})
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The poll on your chirp \"%s\" has closed. Final results:\n\n", chirp.Body)
+	for _, option := range options {
+		fmt.Fprintf(&b, "  %s: %d\n", option.Label, option.Votes)
+	}
+	return sendEmail(ctx, cfg, mailer.Message{To: author.Email, Subject: "Your Chirpy poll has closed", Body: b.String()})
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/notification"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/internal/profile"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// respondProfile looks up a public profile. Users who blocked each other
+// can't see each other's profile.
+func respondProfile(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, params database.GetPublicProfileParams) {
+	row, err := cfg.DB.GetPublicProfile(r.Context(), params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if viewer := actorFromContext(r.Context()); viewer.Valid && viewer.UUID != row.ID {
+		blocked, err := cfg.DB.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{BlockerID: viewer.UUID, BlockedID: row.ID})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to load profile: %v", err))
+			return
+		}
+		if blocked {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, models.Profile{
+		ID:             row.ID,
+		CreatedAt:      row.CreatedAt,
+		Handle:         row.Handle.String,
+		DisplayName:    row.DisplayName,
+		Bio:            row.Bio,
+		Location:       row.Location,
+		Website:        row.Website,
+		AvatarURL:      row.AvatarUrl,
+		ChirpyRed:      row.IsChirpyRed,
+		FollowerCount:  row.FollowerCount,
+		FollowingCount: row.FollowingCount,
+		ChirpCount:     row.ChirpCount,
+	})
+}
+
+func GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	respondProfile(w, r, cfg, database.GetPublicProfileParams{ID: uuid.NullUUID{UUID: id, Valid: true}})
+}
+
+func GetUserProfileByHandle(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	handle := r.PathValue("handle")
+	if profile.ValidateHandle(handle) != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	respondProfile(w, r, cfg, database.GetPublicProfileParams{Handle: sql.NullString{String: handle, Valid: true}})
+}
+
+func nullStringPtr(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *value, Valid: true}
+}
+
+// UpdateProfile applies a partial update: fields left out of the body keep
+// their current value, and an empty string clears a field.
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		Location    *string `json:"location"`
+		Website     *string `json:"website"`
+		AvatarURL   *string `json:"avatar_url"`
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var validationErrors []error
+	if params.Handle != nil {
+		validationErrors = append(validationErrors, profile.ValidateHandle(*params.Handle))
+	}
+	if params.DisplayName != nil {
+		validationErrors = append(validationErrors, profile.ValidateText("display_name", *params.DisplayName, profile.MaxDisplayNameLength))
+	}
+	if params.Bio != nil {
+		validationErrors = append(validationErrors, profile.ValidateText("bio", *params.Bio, profile.MaxBioLength))
+	}
+	if params.Location != nil {
+		validationErrors = append(validationErrors, profile.ValidateText("location", *params.Location, profile.MaxLocationLength))
+	}
+	if params.Website != nil {
+		validationErrors = append(validationErrors, profile.ValidateURL("website", *params.Website))
+	}
+	if params.AvatarURL != nil {
+		validationErrors = append(validationErrors, profile.ValidateURL("avatar_url", *params.AvatarURL))
+	}
+	for _, err := range validationErrors {
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	}
+
+	user, err := cfg.DB.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		Handle:      nullStringPtr(params.Handle),
+		DisplayName: nullStringPtr(params.DisplayName),
+		Bio:         nullStringPtr(params.Bio),
+		Location:    nullStringPtr(params.Location),
+		Website:     nullStringPtr(params.Website),
+		AvatarUrl:   nullStringPtr(params.AvatarURL),
+		ID:          uuidUser,
+	})
+	if database.IsUniqueViolation(err) {
+		utils.RespondWithError(w, http.StatusConflict, "Handle is already taken")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update profile: %v", err))
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, models.User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		ChirpyRed:     chirpyRed(r.Context(), cfg, user.ID),
+		EmailVerified: user.EmailVerified,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		Location:      user.Location,
+		Website:       user.Website,
+		AvatarURL:     user.AvatarUrl,
+	})
+}
+
+func FollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, target, ok := relationshipTarget(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	blocked, err := cfg.DB.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{BlockerID: uuidUser, BlockedID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to follow user: %v", err))
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You can't follow this user")
+		return
+	}
+
+	result, err := cfg.DB.CreateFollow(r.Context(), database.CreateFollowParams{FollowerID: uuidUser, FolloweeID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to follow user: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		notifyIntegrators(r.Context(), cfg, outbound.EventUserFollowed, target, map[string]any{"follower_id": uuidUser, "followee_id": target})
+		notify(r.Context(), cfg, target, notification.TypeFollow, uuid.NullUUID{UUID: uuidUser, Valid: true}, uuid.NullUUID{})
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	target, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+
+	result, err := cfg.DB.DeleteFollow(r.Context(), database.DeleteFollowParams{FollowerID: uuidUser, FolloweeID: target})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to unfollow user: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "You don't follow this user")
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/moderation"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const maxReportDetailsLength = 1000
+
+func toReport(report database.Report) models.Report {
+	return models.Report{
+		ID:             report.ID,
+		ReporterID:     report.ReporterID,
+		TargetType:     report.TargetType,
+		TargetID:       report.TargetID,
+		ReportedUserID: report.ReportedUserID,
+		Reason:         report.Reason,
+		Details:        report.Details,
+		Status:         report.Status,
+		ClaimedBy:      nullUUIDPtr(report.ClaimedBy),
+		ClaimedAt:      nullTimePtr(report.ClaimedAt),
+		ResolvedBy:     nullUUIDPtr(report.ResolvedBy),
+		ResolvedAt:     nullTimePtr(report.ResolvedAt),
+		Resolution:     report.Resolution.String,
+		ResolutionNote: report.ResolutionNote,
+		CreatedAt:      report.CreatedAt,
+		UpdatedAt:      report.UpdatedAt,
+	}
+}
+
+// createReport decodes the report body and files it against target. It
+// answers 409 when the reporter already has a pending report on it.
+func createReport(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, reporter uuid.UUID, targetType string, targetID, reportedUserID uuid.UUID) {
+	type requestBody struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !moderation.IsValidReason(params.Reason) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown reason %q", params.Reason))
+		return
+	}
+	if len(params.Details) > maxReportDetailsLength {
+		utils.RespondWithError(w, http.StatusBadRequest, "Details are too long")
+		return
+	}
+
+	report, err := cfg.DB.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID:     reporter,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		ReportedUserID: reportedUserID,
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusConflict, "You already reported this")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to create report: %v", err))
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusCreated, toReport(report))
+}
+
+func ReportChirp(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve chirp Id : %v ", err))
+		return
+	}
+
+	chirp, err := cfg.DB.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{ID: id, ViewerID: uuid.NullUUID{UUID: uuidUser, Valid: true}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("Chirp with ID %s not found", id))
+		return
+	}
+	if chirp.UserID == uuidUser {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't report your own chirp")
+		return
+	}
+
+	createReport(w, r, cfg, uuidUser, moderation.TargetChirp, chirp.ID, chirp.UserID)
+}
+
+func ReportUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
+		return
+	}
+	if id == uuidUser {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't report yourself")
+		return
+	}
+
+	_, err = cfg.DB.GetUserById(r.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	createReport(w, r, cfg, uuidUser, moderation.TargetUser, id, id)
+}
+
+func AdminListReports(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The queue shows open reports unless another status is asked for.
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = moderation.StatusOpen
+	} else if status == "all" {
+		status = ""
+	} else if !moderation.IsValidStatus(status) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown status %q", status))
+		return
+	}
+	targetType := r.URL.Query().Get("target_type")
+	if targetType != "" && targetType != moderation.TargetChirp && targetType != moderation.TargetUser {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown target type %q", targetType))
+		return
+	}
+
+	reports, err := cfg.DB.ListReports(r.Context(), database.ListReportsParams{
+		Status:     status,
+		TargetType: targetType,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list reports: %v", err))
+		return
+	}
+
+	returnReports := []models.Report{}
+	for _, val := range reports {
+		returnReports = append(returnReports, toReport(val))
+	}
+	utils.RespondWithJson(w, http.StatusOK, returnReports)
+}
+
+func AdminClaimReport(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve report Id : %v ", err))
+		return
+	}
+
+	report, err := cfg.DB.ClaimReport(r.Context(), database.ClaimReportParams{ID: id, ClaimedBy: actorFromContext(r.Context())})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := cfg.DB.GetReportById(r.Context(), id); err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "Report not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusConflict, "Report is not open")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to claim report: %v", err))
+		return
+	}
+
+	recordAdminAction(r, cfg, "report.claimed", "report", id.String(), nil)
+
+	utils.RespondWithJson(w, http.StatusOK, toReport(report))
+}
+
+// loadPendingReport fetches a report the current moderator can close: it must
+// be open, or claimed by them.
+func loadPendingReport(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig) (database.Report, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve report Id : %v ", err))
+		return database.Report{}, false
+	}
+
+	report, err := cfg.DB.GetReportById(r.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Report not found")
+		return report, false
+	}
+	if !moderation.IsPending(report.Status) {
+		utils.RespondWithError(w, http.StatusConflict, fmt.Sprintf("Report is already %s", report.Status))
+		return report, false
+	}
+	if report.Status == moderation.StatusClaimed && report.ClaimedBy != actorFromContext(r.Context()) {
+		utils.RespondWithError(w, http.StatusConflict, "Report is claimed by another moderator")
+		return report, false
+	}
+	return report, true
+}
+
+func AdminResolveReport(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, ok := loadPendingReport(w, r, cfg)
+	if !ok {
+		return
+	}
+	if !moderation.IsValidResolution(report.TargetType, params.Action) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Action %q can't be applied to a %s report", params.Action, report.TargetType))
+		return
+	}
+
+	if params.Action == moderation.ResolutionSuspendUser {
+		target, err := cfg.DB.GetUserAuthState(r.Context(), report.ReportedUserID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		actorRole, _ := r.Context().Value(config.RoleKey).(string)
+		if !canSuspend(actorRole, target.Role) {
+			utils.RespondWithError(w, http.StatusForbidden, "You can't suspend this user")
+			return
+		}
+	}
+
+	// The report is closed in the same transaction as the action, and first,
+	// so resolving it twice fails before the action is repeated.
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to resolve report: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	resolution := sql.NullString{String: params.Action, Valid: true}
+	closed, err := qtx.CloseReport(r.Context(), database.CloseReportParams{
+		Status:         moderation.StatusResolved,
+		Resolution:     resolution,
+		ResolutionNote: params.Note,
+		ResolvedBy:     actorFromContext(r.Context()),
+		ID:             report.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusConflict, "Report was closed by another moderator")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to resolve report: %v", err))
+		return
+	}
+
+	switch params.Action {
+	case moderation.ResolutionHideChirp:
+		_, err = qtx.HideChirp(r.Context(), report.TargetID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to hide chirp: %v", err))
+			return
+		}
+	case moderation.ResolutionDeleteChirp:
+		_, err = qtx.DeleteChirpById(r.Context(), report.TargetID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to delete chirp : %v ", err))
+			return
+		}
+	case moderation.ResolutionSuspendUser:
+		reason := fmt.Sprintf("Report %s: %s", report.ID, report.Reason)
+		_, err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+			SuspendedReason: sql.NullString{String: reason, Valid: true},
+			ID:              report.ReportedUserID,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to suspend user: %v", err))
+			return
+		}
+	}
+
+	// Other pending reports on the same target are settled by this decision.
+	err = qtx.ResolvePendingReportsForTarget(r.Context(), database.ResolvePendingReportsForTargetParams{
+		TargetType:     report.TargetType,
+		TargetID:       report.TargetID,
+		Resolution:     resolution,
+		ResolutionNote: fmt.Sprintf("Resolved with report %s", report.ID),
+		ResolvedBy:     actorFromContext(r.Context()),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to resolve duplicate reports: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to resolve report: %v", err))
+		return
+	}
+
+	switch params.Action {
+	case moderation.ResolutionHideChirp:
+		recordAdminAction(r, cfg, "chirp.hidden", "chirp", report.TargetID.String(), map[string]any{"report_id": report.ID})
+		streamChirpDeleted(r.Context(), cfg, report.TargetID, report.ReportedUserID)
+	case moderation.ResolutionDeleteChirp:
+		recordAdminAction(r, cfg, "chirp.deleted", "chirp", report.TargetID.String(), map[string]any{"report_id": report.ID, "author_id": report.ReportedUserID})
+		streamChirpDeleted(r.Context(), cfg, report.TargetID, report.ReportedUserID)
+	case moderation.ResolutionSuspendUser:
+		if err := cfg.DB.RevokeAllRefreshTokensForUser(r.Context(), report.ReportedUserID); err != nil {
+			log.Printf("Error revoking sessions for user %s: %v", report.ReportedUserID, err)
+		}
+		recordAdminAction(r, cfg, "user.suspended", "user", report.ReportedUserID.String(), map[string]any{"report_id": report.ID})
+	}
+
+	recordAdminAction(r, cfg, "report.resolved", "report", report.ID.String(), map[string]any{"action": params.Action})
+
+	utils.RespondWithJson(w, http.StatusOK, toReport(closed))
+}
+
+func AdminDismissReport(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Note string `json:"note"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, ok := loadPendingReport(w, r, cfg)
+	if !ok {
+		return
+	}
+
+	closed, err := cfg.DB.CloseReport(r.Context(), database.CloseReportParams{
+		Status:         moderation.StatusDismissed,
+		ResolutionNote: params.Note,
+		ResolvedBy:     actorFromContext(r.Context()),
+		ID:             report.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusConflict, "Report was closed by another moderator")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to dismiss report: %v", err))
+		return
+	}
+
+	recordAdminAction(r, cfg, "report.dismissed", "report", report.ID.String(), nil)
+
+	utils.RespondWithJson(w, http.StatusOK, toReport(closed))
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// streamHeartbeat is how often an idle event stream sends a comment, so
+// proxies don't close it.
+const streamHeartbeat = 25 * time.Second
+
+// startEventStream sends the headers of a server-sent event stream. When w
+// can't stream it responds with an error and returns false.
+func startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	return flusher, true
+}
+
+// streamEvents writes every value received from events as a server-sent
+// event called name, until the client disconnects or events is closed on
+// shutdown.
+func streamEvents[T any](w http.ResponseWriter, r *http.Request, flusher http.Flusher, events <-chan T, name string, id func(T) string) {
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error encoding %s event %s: %v", name, id(event), err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id(event), name, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/subscription"
+	"github.com/leonardoklaser/Chirpy/models"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+// chirpyRed reports whether userID currently has Chirpy Red. Errors count as
+// not being a member.
+func chirpyRed(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) bool {
+	red, err := cfg.DB.IsChirpyRed(ctx, userID)
+	if err != nil {
+		log.Printf("Error checking subscription of user %s: %v", userID, err)
+		return false
+	}
+	return red
+}
+
+func subscriptionState(sub database.Subscription) subscription.State {
+	return subscription.State{Status: sub.Status, PeriodEnd: sub.CurrentPeriodEnd.Time}
+}
+
+// applySubscriptionEvent moves the subscription of userID through event and
+// records the change in its history. The row is locked so concurrent events
+// for the same user apply one after the other. The webhook event is marked
+// processed in the same transaction, so a crash can't apply it twice.
+func applySubscriptionEvent(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID, event string, periodEnd time.Time, webhookEventID string) (subscription.State, subscription.State, error) {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return subscription.State{}, subscription.State{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	before := subscription.State{}
+	current, err := qtx.GetSubscriptionForUpdate(ctx, userID)
+	if err == nil {
+		before = subscriptionState(current)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return subscription.State{}, subscription.State{}, err
+	}
+
+	after, err := subscription.Apply(before, event, periodEnd, time.Now().UTC())
+	if err != nil {
+		return before, before, err
+	}
+	if err := saveSubscription(ctx, qtx, userID, event, before, after, webhookEventID); err != nil {
+		return before, before, err
+	}
+	err = qtx.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{Source: polkaSource, ID: webhookEventID, Status: webhookStatusProcessed})
+	if err != nil {
+		return before, before, err
+	}
+	return before, after, tx.Commit()
+}
+
+func saveSubscription(ctx context.Context, q *database.Queries, userID uuid.UUID, event string, before, after subscription.State, webhookEventID string) error {
+	periodEnd := sql.NullTime{Time: after.PeriodEnd, Valid: !after.PeriodEnd.IsZero()}
+	_, err := q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: userID, Status: after.Status, CurrentPeriodEnd: periodEnd})
+	if err != nil {
+		return err
+	}
+	return q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		UserID:           userID,
+		EventType:        event,
+		OldStatus:        before.Status,
+		NewStatus:        after.Status,
+		CurrentPeriodEnd: periodEnd,
+		WebhookEventID:   webhookEventID,
+	})
+}
+
+// ExpireLapsedSubscriptions marks memberships whose paid period has ended as
+// expired. Access already stops at the end of the period, so this only keeps
+// the stored status and history accurate.
+func ExpireLapsedSubscriptions(ctx context.Context, cfg *config.ApiConfig) error {
+	lapsed, err := cfg.DB.ListLapsedSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing lapsed subscriptions: %w", err)
+	}
+	for _, sub := range lapsed {
+		if err := expireSubscription(ctx, cfg, sub.UserID); err != nil {
+			return fmt.Errorf("error expiring subscription of user %s: %w", sub.UserID, err)
+		}
+	}
+	return nil
+}
+
+func expireSubscription(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	current, err := qtx.GetSubscriptionForUpdate(ctx, userID)
+	if err != nil {
+		return err
+	}
+	before := subscriptionState(current)
+	after := subscription.Expire(before, time.Now().UTC())
+	if after == before {
+		// Renewed since it was listed.
+		return nil
+	}
+	if err := saveSubscription(ctx, qtx, userID, subscription.StatusExpired, before, after, ""); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	_, err = cfg.Audit.Record(ctx, audit.Event{
+		Action:     audit.ActionSubscription,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Details:    map[string]any{"event": subscription.StatusExpired, "old_status": before.Status, "new_status": after.Status},
+	})
+	if err != nil {
+		log.Printf("Error recording audit event %s: %v", audit.ActionSubscription, err)
+	}
+	return nil
+}
+
+func GetSubscription(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	response := models.Subscription{History: []models.SubscriptionEvent{}}
+	sub, err := cfg.DB.GetSubscription(r.Context(), uuidUser)
+	if err == nil {
+		response.Status = sub.Status
+		response.CurrentPeriodEnd = nullTimePtr(sub.CurrentPeriodEnd)
+		response.ChirpyRed = subscription.IsActive(subscriptionState(sub), time.Now().UTC())
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve subscription: %v", err))
+		return
+	}
+
+	events, err := cfg.DB.ListSubscriptionEvents(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve subscription history: %v", err))
+		return
+	}
+	for _, val := range events {
+		response.History = append(response.History, models.SubscriptionEvent{
+			EventType:        val.EventType,
+			OldStatus:        val.OldStatus,
+			NewStatus:        val.NewStatus,
+			CurrentPeriodEnd: nullTimePtr(val.CurrentPeriodEnd),
+			CreatedAt:        val.CreatedAt,
+		})
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
 	"github.com/leonardoklaser/Chirpy/internal/auth"
 	"github.com/leonardoklaser/Chirpy/internal/config"
 	"github.com/leonardoklaser/Chirpy/utils"
@@ -21,9 +22,12 @@ func RefreshToken(w http.ResponseWriter, r *http.Request){
 		Token string `json:"token"`
 	}
 
-	token, ok := r.Context().Value(config.TokenKey).(string)
-	if !ok {
-		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+	// The refresh token isn't a JWT, so these routes read it directly
+	// instead of going through MiddlewareAuth.
+	token, err := auth.GetBearerToken(&r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
 	}
 
 	exist, err := cfg.DB.GetValidRefreshToken(r.Context(),token)
@@ -44,6 +48,8 @@ func RefreshToken(w http.ResponseWriter, r *http.Request){
 		return
 	}
 	
+	recordAudit(r, cfg, audit.ActionTokenRefreshed, uuid.NullUUID{UUID: user.ID, Valid: true}, "user", user.ID.String(), map[string]any{"kind": "refresh_token"})
+
 	returnToken := responseBody{Token: tokenAcces} 
 
 	utils.RespondWithJson(w, http.StatusOK, returnToken )
@@ -56,9 +62,10 @@ func RevokeRefreshToken(w http.ResponseWriter, r *http.Request){
 		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
 	}
 	
-	token, ok := r.Context().Value(config.TokenKey).(string)
-	if !ok {
-		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+	token, err := auth.GetBearerToken(&r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
 	}
 
 	exist, err := cfg.DB.GetValidRefreshToken(r.Context(),token)
@@ -67,11 +74,19 @@ func RevokeRefreshToken(w http.ResponseWriter, r *http.Request){
 		return
 	}
 
+	user, err := cfg.DB.GetUserForValidRefreshToken(r.Context(),token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Token invalid for this User")
+		return
+	}
+
 	_, err = cfg.DB.RevokeRefreshToken(r.Context(),token)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Error to revoke token")
 		return
 	}
+
+	recordAudit(r, cfg, audit.ActionTokenRevoked, uuid.NullUUID{UUID: user.ID, Valid: true}, "user", user.ID.String(), map[string]any{"kind": "refresh_token"})
 	var nullInterface interface{}
 	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
 }
\ No newline at end of file
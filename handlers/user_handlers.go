@@ -1,20 +1,46 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
 	"github.com/leonardoklaser/Chirpy/internal/auth"
 	"github.com/leonardoklaser/Chirpy/internal/config"
 	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/mailer"
 	"github.com/leonardoklaser/Chirpy/models"
 	"github.com/leonardoklaser/Chirpy/utils"
 )
 
+const invalidCredentials = "Incorrect email or password"
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func dummyPasswordHash(hasher auth.PasswordHasher) string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = hasher.Hash("chirpy-dummy-password")
+	})
+	return dummyHash
+}
+
+// UpdateUser replaces the email and password together. New clients should
+// use ChangeEmail and ChangePassword; like them, this asks for the current
+// password, and a new password signs out every other session.
 func UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	cfg, err := config.New()
@@ -23,8 +49,9 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type requestBody struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -35,12 +62,27 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	previous, ok := confirmCurrentPassword(w, r, cfg, params.CurrentPassword)
 	if !ok {
-		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+	if !validEmail(params.Email) {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, "Invalid email address")
+		return
+	}
+	if params.Password == "" {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, "Password is required")
+		return
+	}
+	passwordChanged := params.Password != params.CurrentPassword
+	if passwordChanged {
+		if err := cfg.PasswordPolicy.Check(params.Password, params.Email); err != nil {
+			utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
 	}
 
-	passwordHashed, err := auth.HashPassword(params.Password)
+	passwordHashed, err := cfg.Hasher.Hash(params.Password)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Error to has password")
 		return
@@ -49,16 +91,63 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	args := database.UpdateUserByIdParams{
 		Email:    params.Email,
 		Password: passwordHashed,
-		ID:       uuidUser,
+		ID:       previous.ID,
 	}
 
-	user, err := cfg.DB.UpdateUserById(r.Context(), args)
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error to update user: %v", err))
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update user: %v", err))
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	user, err := qtx.UpdateUserById(r.Context(), args)
+	if database.IsUniqueViolation(err) {
+		utils.RespondWithError(w, http.StatusConflict, "Email is already in use")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update user: %v", err))
+		return
+	}
+	if previous.Email != user.Email {
+		// Links sent to the old address must not verify the new one.
+		err = qtx.DeleteUnusedUserTokens(r.Context(), database.DeleteUnusedUserTokensParams{UserID: user.ID, Purpose: auth.PurposeVerifyEmail})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update user: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update user: %v", err))
+		return
+	}
+
+	actor := uuid.NullUUID{UUID: user.ID, Valid: true}
+	if previous.Email != user.Email {
+		recordAudit(r, cfg, audit.ActionEmailChanged, actor, "user", user.ID.String(), map[string]any{"old_email": previous.Email, "new_email": user.Email})
+		if err := sendVerificationEmail(r.Context(), cfg, user.ID, user.Email); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		}
+	}
 
-	utils.RespondWithJson(w, http.StatusOK, models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: user.IsChirpyRed.Bool})
+	returnUser := models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: user.IsChirpyRed}
+	if passwordChanged {
+		recordAudit(r, cfg, audit.ActionPasswordChanged, actor, "user", user.ID.String(), nil)
+
+		if err := revokeCredentials(r.Context(), cfg, user.ID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to revoke sessions: %v", err))
+			return
+		}
+		returnUser.Token, returnUser.Refresh_token, err = issueSession(r.Context(), cfg, user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, returnUser)
 
 }
 
@@ -81,7 +170,7 @@ func PostUser(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-	passwordHashed, err := auth.HashPassword(params.Password)
+	passwordHashed, err := cfg.Hasher.Hash(params.Password)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Error to has password")
 		return
@@ -93,7 +182,12 @@ func PostUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userToReturn := models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: user.IsChirpyRed.Bool}
+	err = sendVerificationEmail(r.Context(), cfg, user.ID, user.Email)
+	if err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+	}
+
+	userToReturn := models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, EmailVerified: user.EmailVerified}
 	utils.RespondWithJson(w, http.StatusCreated, userToReturn)
 
 }
@@ -114,10 +208,154 @@ func DeleteUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The truncate cascades to admin_actions and removes the actor, so the
+	// reset goes straight to the audit log, which has no foreign keys.
+	recordAudit(r, cfg, audit.ActionAdminPrefix+"users.deleted", actorFromContext(r.Context()), "user", "", nil)
+
 	var nullInterface interface{}
 	utils.RespondWithJson(w, http.StatusOK, nullInterface)
 }
 
+// loginError is returned by checkCredentials with the status and message
+// that should be shown to the client. reason, when set, is the real cause
+// recorded in the audit log in place of message.
+type loginError struct {
+	status     int
+	message    string
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *loginError) Error() string {
+	return e.message
+}
+
+func respondLoginError(w http.ResponseWriter, err *loginError) {
+	if err.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.retryAfter.Seconds()))))
+	}
+	utils.RespondWithError(w, err.status, err.message)
+}
+
+// recordFailedLogin counts a wrong password and locks the account once the
+// lockout policy says so.
+func recordFailedLogin(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) {
+	failures, err := cfg.DB.RecordFailedLogin(ctx, userID)
+	if err != nil {
+		log.Printf("Error recording failed login for user %s: %v", userID, err)
+		return
+	}
+	if lockout := cfg.Lockout.LockoutFor(int(failures)); lockout > 0 {
+		lockedUntil := sql.NullTime{Time: time.Now().Add(lockout), Valid: true}
+		if err := cfg.DB.LockUserUntil(ctx, database.LockUserUntilParams{LockedUntil: lockedUntil, ID: userID}); err != nil {
+			log.Printf("Error locking user %s: %v", userID, err)
+		}
+	}
+}
+
+// checkCredentials verifies an email and password pair, applying the
+// lockout policy and upgrading outdated password hashes.
+func checkCredentials(ctx context.Context, cfg *config.ApiConfig, email, password string) (database.GetUserByEmailRow, *loginError) {
+	user, err := cfg.DB.GetUserByEmail(ctx, email)
+	if err != nil {
+		// Burn the same hashing work as a real attempt so response times
+		// don't reveal whether the email is registered.
+		cfg.Hasher.Verify(dummyPasswordHash(cfg.Hasher), password)
+		return user, &loginError{status: http.StatusUnauthorized, message: invalidCredentials}
+	}
+
+	// A locked account answers like an unknown email, so the lockout doesn't
+	// reveal which emails are registered.
+	if user.LockedUntil.Valid && user.LockedUntil.Time.After(time.Now()) {
+		cfg.Hasher.Verify(dummyPasswordHash(cfg.Hasher), password)
+		return user, &loginError{status: http.StatusUnauthorized, message: invalidCredentials, reason: "account locked"}
+	}
+
+	err = cfg.Hasher.Verify(user.Password, password)
+	if err != nil {
+		recordFailedLogin(ctx, cfg, user.ID)
+		return user, &loginError{status: http.StatusUnauthorized, message: invalidCredentials}
+	}
+
+	if user.SuspendedAt.Valid {
+		return user, &loginError{status: http.StatusForbidden, message: "Account suspended"}
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := cfg.DB.ResetFailedLogins(ctx, user.ID); err != nil {
+			log.Printf("Error resetting failed logins for user %s: %v", user.ID, err)
+		}
+	}
+
+	if cfg.Hasher.NeedsRehash(user.Password) {
+		rehashed, err := cfg.Hasher.Hash(password)
+		if err == nil {
+			err = cfg.DB.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{Password: rehashed, ID: user.ID})
+		}
+		if err != nil {
+			log.Printf("Error rehashing password for user %s: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// auditLogin records the outcome of a credential check. userID is uuid.Nil
+// when the email didn't match any account.
+func auditLogin(r *http.Request, cfg *config.ApiConfig, userID uuid.UUID, email string, loginErr *loginError, details map[string]any) {
+	if details == nil {
+		details = map[string]any{}
+	}
+	details["email"] = email
+	action := audit.ActionLoginSucceeded
+	if loginErr != nil {
+		action = audit.ActionLoginFailed
+		details["reason"] = loginErr.message
+		if loginErr.reason != "" {
+			details["reason"] = loginErr.reason
+		}
+	}
+	actor := uuid.NullUUID{UUID: userID, Valid: loginErr == nil}
+	targetID := ""
+	if userID != uuid.Nil {
+		targetID = userID.String()
+	}
+	recordAudit(r, cfg, action, actor, "user", targetID, details)
+}
+
+// revokeCredentials signs userID out everywhere after a password change.
+// refresh_tokens holds both first-party sessions and OAuth grants, so this
+// covers OAuth clients as well as personal access tokens.
+func revokeCredentials(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) error {
+	if err := cfg.DB.RevokeAllRefreshTokensForUser(ctx, userID); err != nil {
+		return err
+	}
+	return cfg.DB.RevokePersonalAccessTokensForUser(ctx, userID)
+}
+
+// issueSession creates an access token and a refresh token for userID.
+func issueSession(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID) (string, string, error) {
+	token, err := auth.MakeJWT(userID, cfg.SecretKey, time.Duration(3600)*time.Second)
+	if err != nil {
+		return "", "", fmt.Errorf("Error generating JWT token")
+	}
+
+	refresh_token, _ := auth.MakeRefreshToken()
+	args := database.CreateRefreshTokenParams{
+		Token: refresh_token,
+		UserID: userID,
+		ExpiresAt: sql.NullTime{
+			Time: time.Now().Add((24 * time.Hour) * 60 ),
+			Valid: true,
+		},
+	}
+	_, err = cfg.DB.CreateRefreshToken(ctx, args)
+	if err != nil {
+		return "", "", fmt.Errorf("Error generating refresh Token")
+	}
+	return token, refresh_token, nil
+}
+
 func LoginUser(w http.ResponseWriter, r *http.Request) {
 	cfg, err := config.New()
 	if err != nil {
@@ -137,39 +375,225 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
+	user, loginErr := checkCredentials(r.Context(), cfg, params.Email, params.Password)
+	auditLogin(r, cfg, user.ID, params.Email, loginErr, nil)
+	if loginErr != nil {
+		respondLoginError(w, loginErr)
+		return
+	}
+	restoreAccount(r, cfg, user.ID)
+
+	token, refresh_token, err := issueSession(r.Context(), cfg, user.ID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Email nao cadastrado")
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	err = auth.CheckPasswordHash(user.Password, params.Password)
+	utils.RespondWithJson(w, http.StatusOK, models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: user.IsChirpyRed, EmailVerified: user.EmailVerified, Role: user.Role, Token: token, Refresh_token: refresh_token})
+
+}
+
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uid, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Incorrect password")
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error to retrieve user Id : %v ", err))
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.SecretKey, time.Duration(3600)*time.Second)
+	result, err := cfg.DB.UnlockUser(r.Context(), uid)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating JWT token")
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to unlock user: %v", err))
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	refresh_token, _ := auth.MakeRefreshToken()
-	args := database.CreateRefreshTokenParams{
-		Token: refresh_token,
-		UserID: user.ID,
-		ExpiresAt: sql.NullTime{
-			Time: time.Now().Add((24 * time.Hour) * 60 ),
-			Valid: true,
-		},
+	recordAdminAction(r, cfg, "user.unlocked", "user", uid.String(), nil)
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// confirmCurrentPassword loads the caller and checks their current password,
+// counting wrong guesses towards the login lockout. It answers the request
+// itself when the check fails.
+func confirmCurrentPassword(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, password string) (database.User, bool) {
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return database.User{}, false
+	}
+
+	user, err := cfg.DB.GetUserById(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return user, false
+	}
+	if user.LockedUntil.Valid && user.LockedUntil.Time.After(time.Now()) {
+		respondLoginError(w, &loginError{status: http.StatusTooManyRequests, message: "Too many failed login attempts, try again later", retryAfter: time.Until(user.LockedUntil.Time)})
+		return user, false
+	}
+	if err := cfg.Hasher.Verify(user.Password, password); err != nil {
+		recordFailedLogin(r.Context(), cfg, user.ID)
+		utils.RespondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return user, false
+	}
+	return user, true
+}
+
+func ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		CurrentPassword string `json:"current_password"`
+		Email           string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	previous, ok := confirmCurrentPassword(w, r, cfg, params.CurrentPassword)
+	if !ok {
+		return
+	}
+	if !validEmail(params.Email) {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, "Invalid email address")
+		return
+	}
+	if strings.EqualFold(params.Email, previous.Email) {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, "New email must be different from the current one")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update email: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	user, err := qtx.UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{Email: params.Email, ID: previous.ID})
+	if database.IsUniqueViolation(err) {
+		utils.RespondWithError(w, http.StatusConflict, "Email is already in use")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update email: %v", err))
+		return
 	}
-	_, err = cfg.DB.CreateRefreshToken(r.Context(), args)
+	// Links sent to the old address must not verify the new one.
+	err = qtx.DeleteUnusedUserTokens(r.Context(), database.DeleteUnusedUserTokensParams{UserID: user.ID, Purpose: auth.PurposeVerifyEmail})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating refresh Token")
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update email: %v", err))
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update email: %v", err))
+		return
+	}
+
+	recordAudit(r, cfg, audit.ActionEmailChanged, uuid.NullUUID{UUID: user.ID, Valid: true}, "user", user.ID.String(), map[string]any{"old_email": previous.Email, "new_email": user.Email})
+
+	err = sendVerificationEmail(r.Context(), cfg, user.ID, user.Email)
+	if err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+	}
+	// Let the old address know, in case the change wasn't made by its owner.
+	notice := mailer.Message{
+		To:      previous.Email,
+		Subject: "Your Chirpy email was changed",
+		Body:    fmt.Sprintf("The email on your Chirpy account was changed to %s.\n\nIf you didn't do this, reset your password right away.\n", user.Email),
+	}
+	if err := sendEmail(r.Context(), cfg, notice); err != nil {
+		log.Printf("Error notifying previous email of user %s: %v", user.ID, err)
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: chirpyRed(r.Context(), cfg, user.ID), EmailVerified: user.EmailVerified})
+}
+
+// ChangePassword sets a new password, signs out every other session and
+// revokes the user's OAuth grants and personal access tokens. The caller gets
+// a fresh token pair in the response.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
 
-	utils.RespondWithJson(w, http.StatusOK, models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: user.IsChirpyRed.Bool ,Token: token, Refresh_token: refresh_token})
+	type requestBody struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
 
-}
\ No newline at end of file
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, ok := confirmCurrentPassword(w, r, cfg, params.CurrentPassword)
+	if !ok {
+		return
+	}
+	if params.NewPassword == params.CurrentPassword {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, "New password must be different from the current one")
+		return
+	}
+	if err := cfg.PasswordPolicy.Check(params.NewPassword, user.Email); err != nil {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	passwordHashed, err := cfg.Hasher.Hash(params.NewPassword)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error to has password")
+		return
+	}
+
+	err = cfg.DB.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{Password: passwordHashed, ID: user.ID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update password: %v", err))
+		return
+	}
+	if err := cfg.DB.ResetFailedLogins(r.Context(), user.ID); err != nil {
+		log.Printf("Error resetting failed logins for user %s: %v", user.ID, err)
+	}
+
+	recordAudit(r, cfg, audit.ActionPasswordChanged, uuid.NullUUID{UUID: user.ID, Valid: true}, "user", user.ID.String(), nil)
+
+	err = revokeCredentials(r.Context(), cfg, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to revoke sessions: %v", err))
+		return
+	}
+	token, refresh_token, err := issueSession(r.Context(), cfg, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJson(w, http.StatusOK, models.User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, ChirpyRed: chirpyRed(r.Context(), cfg, user.ID), EmailVerified: user.EmailVerified, Role: user.Role, Token: token, Refresh_token: refresh_token})
+}
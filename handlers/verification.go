@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/mailer"
+	"github.com/leonardoklaser/Chirpy/utils"
+)
+
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	passwordResetTTL = time.Hour
+)
+
+// sendUserToken issues a signed single-use token for purpose, records its
+// nonce and emails the user a link containing it. The token is only valid
+// while email is still the user's address.
+func sendUserToken(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID, email, purpose string, expiresIn time.Duration, subject, path string) error {
+	token, claims, err := auth.MakeSignedToken(purpose, userID, email, cfg.SecretKey, expiresIn)
+	if err != nil {
+		return fmt.Errorf("error generating token: %w", err)
+	}
+
+	err = cfg.DB.CreateUserToken(ctx, database.CreateUserTokenParams{
+		TokenHash: auth.HashToken(claims.Nonce),
+		UserID:    userID,
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error storing token: %w", err)
+	}
+
+	link := fmt.Sprintf("%s%s?token=%s", cfg.BaseURL, path, url.QueryEscape(token))
+	body := fmt.Sprintf("Open the link below to continue:\n\n%s\n\nOr use this token: %s\n\nThe link expires in %s.\n", link, token, expiresIn)
+	return sendEmail(ctx, cfg, mailer.Message{To: email, Subject: subject, Body: body})
+}
+
+func sendVerificationEmail(ctx context.Context, cfg *config.ApiConfig, userID uuid.UUID, email string) error {
+	return sendUserToken(ctx, cfg, userID, email, auth.PurposeVerifyEmail, verifyEmailTTL, "Verify your Chirpy email", "/api/users/verify")
+}
+
+// consumeUserToken checks the signature of token and marks it used, and
+// returns the user and the address it was sent to. It fails if the token was
+// already consumed, even when the signature is still valid, or if the user's
+// address changed since it was sent.
+func consumeUserToken(ctx context.Context, cfg *config.ApiConfig, token, purpose string) (uuid.UUID, string, error) {
+	claims, err := auth.ParseSignedToken(token, purpose, cfg.SecretKey)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	consumed, err := cfg.DB.ConsumeUserToken(ctx, database.ConsumeUserTokenParams{TokenHash: auth.HashToken(claims.Nonce), Purpose: purpose})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (consumed.UserID != claims.UserID || consumed.Email != claims.Email)) {
+		return uuid.Nil, "", auth.ErrInvalidSignedToken
+	}
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return consumed.UserID, consumed.Email, nil
+}
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, email, err := consumeUserToken(r.Context(), cfg, params.Token, auth.PurposeVerifyEmail)
+	if errors.Is(err, auth.ErrInvalidSignedToken) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to verify token: %v", err))
+		return
+	}
+
+	err = cfg.DB.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{ID: userID, Email: email})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to verify email: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
+
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	uuidUser, ok := r.Context().Value(config.UserIDKey).(uuid.UUID)
+	if !ok {
+		utils.RespondWithError(w, 500, fmt.Sprintf("omg you're so bad at this"))
+		return
+	}
+
+	user, err := cfg.DB.GetUserById(r.Context(), uuidUser)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.EmailVerified {
+		utils.RespondWithError(w, http.StatusConflict, "Email already verified")
+		return
+	}
+
+	err = sendVerificationEmail(r.Context(), cfg, user.ID, user.Email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to send verification email: %v", err))
+		return
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusAccepted, nullInterface)
+}
+
+func RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Always answer 202 so this endpoint can't be used to probe for accounts.
+	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
+	if err == nil {
+		err = sendUserToken(r.Context(), cfg, user.ID, user.Email, auth.PurposePasswordReset, passwordResetTTL, "Reset your Chirpy password", "/api/password-reset/confirm")
+		if err != nil {
+			log.Printf("Error sending password reset to user %s: %v", user.ID, err)
+		}
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusAccepted, nullInterface)
+}
+
+func ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest ,"Error to retrieve server configurations")
+	}
+
+	type requestBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestBody{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if params.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+	// Check the policy against the account's address before the token is
+	// consumed, so a rejected password doesn't use up the link.
+	claims, err := auth.ParseSignedToken(params.Token, auth.PurposePasswordReset, cfg.SecretKey)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := cfg.DB.GetUserById(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, auth.ErrInvalidSignedToken.Error())
+		return
+	}
+	if err := cfg.PasswordPolicy.Check(params.Password, user.Email); err != nil {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	userID, email, err := consumeUserToken(r.Context(), cfg, params.Token, auth.PurposePasswordReset)
+	if errors.Is(err, auth.ErrInvalidSignedToken) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to verify token: %v", err))
+		return
+	}
+
+	passwordHashed, err := cfg.Hasher.Hash(params.Password)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error to has password")
+		return
+	}
+
+	err = cfg.DB.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{Password: passwordHashed, ID: userID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update password: %v", err))
+		return
+	}
+
+	recordAudit(r, cfg, audit.ActionPasswordChanged, uuid.NullUUID{UUID: userID, Valid: true}, "user", userID.String(), map[string]any{"via": "password_reset"})
+
+	// The reset link proves control of the inbox, so the account is verified
+	// and any lockout or existing session is cleared.
+	if err := cfg.DB.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{ID: userID, Email: email}); err != nil {
+		log.Printf("Error marking email verified for user %s: %v", userID, err)
+	}
+	if err := cfg.DB.ResetFailedLogins(r.Context(), userID); err != nil {
+		log.Printf("Error resetting failed logins for user %s: %v", userID, err)
+	}
+	if err := revokeCredentials(r.Context(), cfg, userID); err != nil {
+		log.Printf("Error revoking sessions for user %s: %v", userID, err)
+	}
+
+	var nullInterface interface{}
+	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+}
@@ -1,13 +1,54 @@
 package handlers
 
 import (
-	"net/http"
-	"github.com/leonardoklaser/Chirpy/internal/config"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
+	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/notification"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/internal/subscription"
+	"github.com/leonardoklaser/Chirpy/models"
 	"github.com/leonardoklaser/Chirpy/utils"
 )
 
+const (
+	polkaSource = "polka"
+
+	webhookStatusProcessed = "processed"
+	webhookStatusDuplicate = "duplicate"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+func respondWebhookStatus(w http.ResponseWriter, status string) {
+	type responseBody struct {
+		Status string `json:"status"`
+	}
+	utils.RespondWithJson(w, http.StatusOK, responseBody{Status: status})
+}
+
+func finishWebhookEvent(r *http.Request, cfg *config.ApiConfig, eventID, status, message string) {
+	err := cfg.DB.FinishWebhookEvent(r.Context(), database.FinishWebhookEventParams{Source: polkaSource, ID: eventID, Status: status, Error: message})
+	if err != nil {
+		log.Printf("Error updating webhook event %s: %v", eventID, err)
+	}
+}
+
+// PolkaWebhook applies a Polka event at most once. Events are identified by
+// their id, falling back to the X-Polka-Event-Id header and then to a hash of
+// the body, so a redelivered event is answered with "duplicate".
 func PolkaWebhook(w http.ResponseWriter, r *http.Request){
 
 	cfg, err := config.New()
@@ -16,31 +57,136 @@ func PolkaWebhook(w http.ResponseWriter, r *http.Request){
 	}
 
 	type DataUserId struct {
-                UserId uuid.UUID `json:"user_id"`
-        }
+		UserId    uuid.UUID  `json:"user_id"`
+		PeriodEnd *time.Time `json:"period_end"`
+	}
 
 	type requestBody struct{
-		Event string `json:"event"`
-		Data DataUserId `json:"data"`
+		ID    string     `json:"id"`
+		Event string     `json:"event"`
+		Data  DataUserId `json:"data"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, config.MaxWebhookBodyBytes))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Webhook body is too large")
+		return
+	}
 	params := requestBody{}
-	err = decoder.Decode(&params)
+	err = json.Unmarshal(body, &params)
 	if err != nil{
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-	var nullInterface interface{}
-	if params.Event == "user.upgraded" {
-		_,err := cfg.DB.UpgradeToRed(r.Context(), params.Data.UserId)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusNotFound, "User not found")
-                	return
-		}
+
+	eventID := params.ID
+	if eventID == "" {
+		eventID = r.Header.Get("X-Polka-Event-Id")
+	}
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	_, err = cfg.DB.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		Source:    polkaSource,
+		ID:        eventID,
+		EventType: params.Event,
+		Payload:   string(body),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWebhookStatus(w, webhookStatusDuplicate)
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to record webhook event: %v", err))
+		return
+	}
+
+	if !subscription.IsKnownEvent(params.Event) {
+		finishWebhookEvent(r, cfg, eventID, webhookStatusIgnored, "")
+		respondWebhookStatus(w, webhookStatusIgnored)
+		return
+	}
+
+	// Failed events are recorded as such so Polka's retry processes them
+	// again instead of being answered as a duplicate.
+	_, err = cfg.DB.GetUserById(r.Context(), params.Data.UserId)
+	if errors.Is(err, sql.ErrNoRows) {
+		finishWebhookEvent(r, cfg, eventID, webhookStatusFailed, "user not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		finishWebhookEvent(r, cfg, eventID, webhookStatusFailed, err.Error())
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to retrieve user: %v", err))
+		return
+	}
+
+	periodEnd := time.Time{}
+	if params.Data.PeriodEnd != nil {
+		periodEnd = params.Data.PeriodEnd.UTC()
+	}
+	before, after, err := applySubscriptionEvent(r.Context(), cfg, params.Data.UserId, params.Event, periodEnd, eventID)
+	if err != nil {
+		finishWebhookEvent(r, cfg, eventID, webhookStatusFailed, err.Error())
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to update subscription: %v", err))
+		return
+	}
+
+	action := audit.ActionSubscription
+	if params.Event == subscription.EventUpgraded {
+		action = audit.ActionUserUpgraded
+		notifyIntegrators(r.Context(), cfg, outbound.EventUserUpgraded, params.Data.UserId, map[string]any{
+			"user_id":            params.Data.UserId,
+			"current_period_end": after.PeriodEnd,
+		})
+		notify(r.Context(), cfg, params.Data.UserId, notification.TypeChirpyRed, uuid.NullUUID{}, uuid.NullUUID{})
+	}
+	recordAudit(r, cfg, action, uuid.NullUUID{}, "user", params.Data.UserId.String(), map[string]any{
+		"source":     polkaSource,
+		"event_id":   eventID,
+		"event":      params.Event,
+		"old_status": before.Status,
+		"new_status": after.Status,
+	})
+
+	respondWebhookStatus(w, webhookStatusProcessed)
+}
+
+// AdminListWebhookEvents lists received webhook events with their raw
+// payloads, newest first.
+func AdminListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.New()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Error to retrieve server configurations")
 	}
 
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	utils.RespondWithJson(w, http.StatusNoContent, nullInterface)
+	events, err := cfg.DB.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error to list webhook events: %v", err))
+		return
+	}
 
+	response := []models.WebhookEvent{}
+	for _, val := range events {
+		response = append(response, models.WebhookEvent{
+			Source:      val.Source,
+			ID:          val.ID,
+			EventType:   val.EventType,
+			Payload:     val.Payload,
+			Status:      val.Status,
+			Error:       val.Error,
+			Attempts:    val.Attempts,
+			ReceivedAt:  val.ReceivedAt,
+			ProcessedAt: nullTimePtr(val.ProcessedAt),
+		})
+	}
+	utils.RespondWithJson(w, http.StatusOK, response)
 }
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+)
+
+const (
+	ActionLoginSucceeded    = "login.succeeded"
+	ActionLoginFailed       = "login.failed"
+	ActionTokenRefreshed    = "token.refreshed"
+	ActionTokenRevoked      = "token.revoked"
+	ActionEmailChanged      = "user.email_changed"
+	ActionPasswordChanged   = "user.password_changed"
+	ActionChirpDeleted      = "chirp.deleted"
+	ActionUserUpgraded      = "user.upgraded"
+	ActionSubscription      = "user.subscription_changed"
+	ActionDeletionRequested = "user.deletion_requested"
+	ActionDeletionCancelled = "user.deletion_cancelled"
+	ActionUserPurged        = "user.purged"
+	ActionDataExported      = "user.data_exported"
+	ActionAdminPrefix       = "admin."
+)
+
+// Event is a security-relevant action to append to the audit log.
+type Event struct {
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	IP         string
+	RequestID  string
+	Details    map[string]any
+}
+
+// Recorder appends events to the audit_events table. Every row stores the
+// hash of the previous row, so editing or removing a row breaks the chain.
+type Recorder struct {
+	db *sql.DB
+}
+
+func NewRecorder(db *sql.DB) *Recorder {
+	return &Recorder{db: db}
+}
+
+func (r *Recorder) Record(ctx context.Context, e Event) (database.AuditEvent, error) {
+	details := e.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	rawDetails, err := json.Marshal(details)
+	if err != nil {
+		return database.AuditEvent{}, fmt.Errorf("error encoding audit details: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.AuditEvent{}, err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	// Serialize writers so each row links to the one inserted before it.
+	if err := q.LockAuditChain(ctx); err != nil {
+		return database.AuditEvent{}, err
+	}
+	prevHash, err := q.GetLastAuditHash(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.AuditEvent{}, err
+	}
+
+	params := database.CreateAuditEventParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		Action:     e.Action,
+		ActorID:    e.ActorID,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Ip:         e.IP,
+		RequestID:  e.RequestID,
+		Details:    rawDetails,
+		PrevHash:   prevHash,
+	}
+	params.Hash = ComputeHash(database.AuditEvent{
+		ID:         params.ID,
+		CreatedAt:  params.CreatedAt,
+		Action:     params.Action,
+		ActorID:    params.ActorID,
+		TargetType: params.TargetType,
+		TargetID:   params.TargetID,
+		Ip:         params.Ip,
+		RequestID:  params.RequestID,
+		Details:    params.Details,
+		PrevHash:   params.PrevHash,
+	})
+
+	event, err := q.CreateAuditEvent(ctx, params)
+	if err != nil {
+		return database.AuditEvent{}, err
+	}
+	return event, tx.Commit()
+}
+
+// ComputeHash returns the chained hash of an event: SHA-256 over the previous
+// hash and every recorded field.
+func ComputeHash(e database.AuditEvent) string {
+	actor := ""
+	if e.ActorID.Valid {
+		actor = e.ActorID.UUID.String()
+	}
+	fields := []string{
+		e.PrevHash,
+		e.ID.String(),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Action,
+		actor,
+		e.TargetType,
+		e.TargetID,
+		e.Ip,
+		e.RequestID,
+		string(e.Details),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyChain checks events, in seq order, against prevHash and their own
+// stored hash. It returns the hash of the last event so a long log can be
+// verified in batches, and the seq of the first broken event if any.
+func VerifyChain(prevHash string, events []database.AuditEvent) (lastHash string, brokenSeq int64, ok bool) {
+	for _, e := range events {
+		if e.PrevHash != prevHash || ComputeHash(e) != e.Hash {
+			return prevHash, e.Seq, false
+		}
+		prevHash = e.Hash
+	}
+	return prevHash, 0, true
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+)
+
+func buildChain(n int) []database.AuditEvent {
+	events := []database.AuditEvent{}
+	prevHash := ""
+	for i := 0; i < n; i++ {
+		e := database.AuditEvent{
+			Seq:        int64(i + 1),
+			ID:         uuid.New(),
+			CreatedAt:  time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC),
+			Action:     ActionLoginSucceeded,
+			ActorID:    uuid.NullUUID{UUID: uuid.New(), Valid: true},
+			TargetType: "user",
+			TargetID:   uuid.NewString(),
+			Ip:         "127.0.0.1",
+			RequestID:  "req",
+			Details:    json.RawMessage(`{"n":1}`),
+			PrevHash:   prevHash,
+		}
+		e.Hash = ComputeHash(e)
+		prevHash = e.Hash
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		Name       string
+		Tamper     func(events []database.AuditEvent) []database.AuditEvent
+		ExpectedOK bool
+		BrokenSeq  int64
+	}{
+		{
+			Name:       "untouched",
+			Tamper:     func(events []database.AuditEvent) []database.AuditEvent { return events },
+			ExpectedOK: true,
+		},
+		{
+			Name: "edited details",
+			Tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[2].Details = json.RawMessage(`{"n":2}`)
+				return events
+			},
+			BrokenSeq: 3,
+		},
+		{
+			Name: "edited and rehashed row",
+			Tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[1].Action = ActionLoginFailed
+				events[1].Hash = ComputeHash(events[1])
+				return events
+			},
+			BrokenSeq: 3,
+		},
+		{
+			Name: "deleted row",
+			Tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			BrokenSeq: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		events := tt.Tamper(buildChain(5))
+		_, brokenSeq, ok := VerifyChain("", events)
+		if ok != tt.ExpectedOK || brokenSeq != tt.BrokenSeq {
+			t.Errorf("%s: VerifyChain returned ok=%v seq=%d, expected ok=%v seq=%d", tt.Name, ok, brokenSeq, tt.ExpectedOK, tt.BrokenSeq)
+		}
+	}
+}
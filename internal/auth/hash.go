@@ -1,24 +1,182 @@
 package auth
 
-import(
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error){
+// UnsetPassword is the placeholder written by migration 003 for users created
+// before passwords existed. It must never be accepted as a credential.
+const UnsetPassword = "unset"
+
+var (
+	ErrPasswordMismatch = errors.New("password does not match")
+	ErrPasswordUnset    = errors.New("account has no password set")
+	ErrUnknownHash      = errors.New("unknown password hash format")
+)
+
+// PasswordHasher hashes new passwords and verifies stored ones. NeedsRehash
+// reports whether a stored hash was produced with an outdated algorithm or
+// parameters and should be replaced after a successful login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) error
+	NeedsRehash(hash string) bool
+}
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
 
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil{
+const (
+	// MaxArgon2Memory (in KiB) and MaxArgon2Iterations bound the cost of a
+	// single hash, so a typo can't make every login exhaust the server.
+	MaxArgon2Memory     = 4 << 20
+	MaxArgon2Iterations = 100
+)
+
+// Validate checks that p can be passed to argon2.IDKey.
+func (p Argon2Params) Validate() error {
+	switch {
+	case p.Iterations < 1 || p.Iterations > MaxArgon2Iterations:
+		return fmt.Errorf("iterations must be between 1 and %d", MaxArgon2Iterations)
+	case p.Parallelism < 1:
+		return errors.New("parallelism must be at least 1")
+	case p.Memory < 8*uint32(p.Parallelism) || p.Memory > MaxArgon2Memory:
+		return fmt.Errorf("memory must be between %d and %d KiB", 8*uint32(p.Parallelism), MaxArgon2Memory)
+	case p.SaltLength < 8 || p.KeyLength < 16:
+		return errors.New("salt must be at least 8 bytes and the key at least 16")
+	}
+	return nil
+}
+
+// Argon2Hasher hashes with argon2id and still verifies legacy bcrypt hashes.
+type Argon2Hasher struct {
+	Params Argon2Params
+}
+
+func NewArgon2Hasher(params Argon2Params) *Argon2Hasher {
+	return &Argon2Hasher{Params: params}
+}
+
+var defaultHasher PasswordHasher = NewArgon2Hasher(DefaultArgon2Params)
+
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(hashPassword), nil
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2Hasher) Verify(hash, password string) error {
+	switch {
+	case hash == UnsetPassword:
+		return ErrPasswordUnset
+	case isBcryptHash(hash):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+	return ErrUnknownHash
+}
+
+func (h *Argon2Hasher) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return true
+	}
+	params, salt, _, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Params.Memory ||
+		params.Iterations != h.Params.Iterations ||
+		params.Parallelism != h.Params.Parallelism ||
+		params.KeyLength != h.Params.KeyLength ||
+		uint32(len(salt)) != h.Params.SaltLength
+}
 
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
 }
 
-func CheckPasswordHash(hash, password string) (error){
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+func decodeArgon2Hash(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	params := Argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	// argon2.IDKey panics on zero parameters.
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return err
+		return Argon2Params{}, nil, nil, ErrUnknownHash
 	}
-	return nil
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
 
-}
\ No newline at end of file
+	return params, salt, key, nil
+}
+
+func HashPassword(password string) (string, error) {
+	return defaultHasher.Hash(password)
+}
+
+func CheckPasswordHash(hash, password string) error {
+	return defaultHasher.Verify(hash, password)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2Hasher(t *testing.T) {
+	hasher := NewArgon2Hasher(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt failed: %v", err)
+	}
+	current, err := hasher.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	outdated, err := NewArgon2Hasher(Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	tests := []struct {
+		Name          string
+		Hash          string
+		Password      string
+		ExpectedError error
+		NeedsRehash   bool
+	}{
+		{Name: "argon2id match", Hash: current, Password: "hunter2", ExpectedError: nil, NeedsRehash: false},
+		{Name: "argon2id mismatch", Hash: current, Password: "hunter3", ExpectedError: ErrPasswordMismatch, NeedsRehash: false},
+		{Name: "argon2id outdated params", Hash: outdated, Password: "hunter2", ExpectedError: nil, NeedsRehash: true},
+		{Name: "legacy bcrypt", Hash: string(legacy), Password: "hunter2", ExpectedError: nil, NeedsRehash: true},
+		{Name: "legacy bcrypt mismatch", Hash: string(legacy), Password: "hunter3", ExpectedError: ErrPasswordMismatch, NeedsRehash: true},
+		{Name: "unset placeholder", Hash: UnsetPassword, Password: UnsetPassword, ExpectedError: ErrPasswordUnset, NeedsRehash: true},
+		{Name: "garbage", Hash: "plaintext", Password: "plaintext", ExpectedError: ErrUnknownHash, NeedsRehash: true},
+		{Name: "zero parallelism", Hash: "$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U", Password: "hunter2", ExpectedError: ErrUnknownHash, NeedsRehash: true},
+		{Name: "zero iterations", Hash: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U", Password: "hunter2", ExpectedError: ErrUnknownHash, NeedsRehash: true},
+	}
+
+	for _, tt := range tests {
+		err := hasher.Verify(tt.Hash, tt.Password)
+		if !errors.Is(err, tt.ExpectedError) {
+			t.Errorf("%s: Verify returned %v, expected %v", tt.Name, err, tt.ExpectedError)
+		}
+		if got := hasher.NeedsRehash(tt.Hash); got != tt.NeedsRehash {
+			t.Errorf("%s: NeedsRehash returned %v, expected %v", tt.Name, got, tt.NeedsRehash)
+		}
+	}
+}
+
+func TestArgon2ParamsValidate(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Params        Argon2Params
+		ExpectedError bool
+	}{
+		{Name: "defaults", Params: DefaultArgon2Params, ExpectedError: false},
+		{Name: "zero parallelism", Params: Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32}, ExpectedError: true},
+		{Name: "zero iterations", Params: Argon2Params{Memory: 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32}, ExpectedError: true},
+		{Name: "too little memory", Params: Argon2Params{Memory: 8, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}, ExpectedError: true},
+		{Name: "too much memory", Params: Argon2Params{Memory: MaxArgon2Memory + 1, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, ExpectedError: true},
+		{Name: "short key", Params: Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 4}, ExpectedError: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.Params.Validate()
+		if (err != nil) != tt.ExpectedError {
+			t.Errorf("%s: Validate returned %v, expected error: %v", tt.Name, err, tt.ExpectedError)
+		}
+	}
+}
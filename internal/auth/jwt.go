@@ -3,6 +3,7 @@ package auth
 import(
 	"github.com/google/uuid"
 	"fmt"
+	"strings"
 	"time"
 	"github.com/golang-jwt/jwt/v5"
 	"errors"
@@ -54,4 +55,59 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error){
 	}
 
 	return userId, nil
-}
\ No newline at end of file
+}
+
+// ScopedClaims are carried by access tokens issued to OAuth clients. First
+// party tokens from MakeJWT have no scope and grant full access.
+type ScopedClaims struct {
+	jwt.RegisteredClaims
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+func MakeScopedJWT(userID uuid.UUID, clientID string, scopes []string, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := ScopedClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			Issuer:    "chirpy",
+			Subject:   userID.String(),
+		},
+		Scope:    strings.Join(scopes, " "),
+		ClientID: clientID,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateScopedJWT validates any Chirpy access token. scopes is nil for
+// first party tokens and the granted scopes for OAuth access tokens.
+func ValidateScopedJWT(tokenString, tokenSecret string) (userID uuid.UUID, scopes []string, err error) {
+	claims := &ScopedClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("Unknown signin type, cannot proceed: " + token.Header["alg"].(string))
+		}
+		return []byte(tokenSecret), nil
+	}, jwt.WithLeeway(5*time.Second))
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	if !token.Valid {
+		return uuid.Nil, nil, errors.New("Invalid token")
+	}
+
+	userID, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("Error to convert Subject into uuid: %v", err)
+	}
+
+	if claims.ClientID != "" {
+		scopes = strings.Fields(claims.Scope)
+		if scopes == nil {
+			scopes = []string{}
+		}
+	}
+	return userID, scopes, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"time"
+)
+
+// LockoutPolicy decides how long an account stays locked after repeated
+// failed logins. Below Threshold failures there is no delay; from there on
+// the delay doubles with each failure until it reaches MaxDelay.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	Threshold: 5,
+	BaseDelay: 30 * time.Second,
+	MaxDelay:  time.Hour,
+}
+
+func (p LockoutPolicy) LockoutFor(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
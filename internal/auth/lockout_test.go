@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutFor(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+
+	tests := []struct {
+		Failures int
+		Expected time.Duration
+	}{
+		{Failures: 0, Expected: 0},
+		{Failures: 2, Expected: 0},
+		{Failures: 3, Expected: 10 * time.Second},
+		{Failures: 4, Expected: 20 * time.Second},
+		{Failures: 5, Expected: 40 * time.Second},
+		{Failures: 6, Expected: time.Minute},
+		{Failures: 100, Expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := policy.LockoutFor(tt.Failures); got != tt.Expected {
+			t.Errorf("LockoutFor(%d) = %v, expected %v", tt.Failures, got, tt.Expected)
+		}
+	}
+}
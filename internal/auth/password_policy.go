@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("password is too weak")
+
+// PasswordPolicy decides whether a new password is strong enough. Passwords
+// shorter than PassphraseLength must mix at least MinClasses of lowercase,
+// uppercase, digits and symbols; longer passphrases only need the length.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	MinClasses       int
+	PassphraseLength int
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:        10,
+	MaxLength:        128,
+	MinClasses:       3,
+	PassphraseLength: 20,
+}
+
+var commonPasswords = []string{"password", "qwerty", "123456", "letmein", "chirpy", "welcome", "iloveyou"}
+
+// Check returns an error wrapping ErrWeakPassword that says what is wrong
+// with password. email is used to reject passwords built from the address.
+func (p PasswordPolicy) Check(password, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if length > p.MaxLength {
+		return fmt.Errorf("%w: use at most %d characters", ErrWeakPassword, p.MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if local, _, _ := strings.Cut(strings.ToLower(email), "@"); len(local) >= 3 && strings.Contains(lower, local) {
+		return fmt.Errorf("%w: it must not contain your email address", ErrWeakPassword)
+	}
+	for _, common := range commonPasswords {
+		if strings.Contains(lower, common) && length < p.PassphraseLength {
+			return fmt.Errorf("%w: it contains a common password", ErrWeakPassword)
+		}
+	}
+
+	if length >= p.PassphraseLength {
+		return nil
+	}
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		return fmt.Errorf("%w: mix at least %d of lowercase, uppercase, digits and symbols, or use %d or more characters", ErrWeakPassword, p.MinClasses, p.PassphraseLength)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy
+
+	tests := []struct {
+		Name     string
+		Password string
+		Email    string
+		IsValid  bool
+	}{
+		{Name: "mixed classes", Password: "Tr0ub4dor&3", Email: "walt@example.com", IsValid: true},
+		{Name: "too short", Password: "Ab1!", Email: "walt@example.com", IsValid: false},
+		{Name: "single class", Password: "abcdefghijkl", Email: "walt@example.com", IsValid: false},
+		{Name: "two classes", Password: "abcdefgh1234", Email: "walt@example.com", IsValid: false},
+		{Name: "long passphrase", Password: "correct horse battery staple", Email: "walt@example.com", IsValid: true},
+		{Name: "contains email", Password: "Heisenberg1!walter", Email: "walter@example.com", IsValid: false},
+		{Name: "common password", Password: "Password123!", Email: "walt@example.com", IsValid: false},
+		{Name: "too long", Password: strings.Repeat("a", 129), Email: "walt@example.com", IsValid: false},
+	}
+
+	for _, tt := range tests {
+		err := policy.Check(tt.Password, tt.Email)
+		if tt.IsValid && err != nil {
+			t.Errorf("%s: Check returned %v, expected no error", tt.Name, err)
+		}
+		if !tt.IsValid && !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("%s: Check returned %v, expected ErrWeakPassword", tt.Name, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix marks long-lived tokens so they can be told apart
+// from JWTs without a database lookup.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+const (
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeChirpsRead   = "chirps:read"
+	ScopeProfileWrite = "profile:write"
+	ScopeProfileRead  = "profile:read"
+)
+
+var knownScopes = map[string]bool{
+	ScopeChirpsWrite:  true,
+	ScopeChirpsRead:   true,
+	ScopeProfileWrite: true,
+	ScopeProfileRead:  true,
+}
+
+func MakePersonalAccessToken() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + hex.EncodeToString(key), nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+func IsKnownScope(scope string) bool {
+	return knownScopes[scope]
+}
+
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
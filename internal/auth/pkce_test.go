@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := strings.Repeat("a1b2c3d4", 6)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		Name     string
+		Verifier string
+		Expected bool
+	}{
+		{Name: "matching verifier", Verifier: verifier, Expected: true},
+		{Name: "different verifier", Verifier: strings.Repeat("z", 48), Expected: false},
+		{Name: "too short", Verifier: "short", Expected: false},
+		{Name: "plain challenge", Verifier: challenge, Expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := VerifyPKCE(tt.Verifier, challenge); got != tt.Expected {
+			t.Errorf("%s: VerifyPKCE returned %v, expected %v", tt.Name, got, tt.Expected)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package auth
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of
+// required. Roles are ordered user < moderator < admin.
+func RoleAtLeast(role, required string) bool {
+	rank, ok := roleRank[role]
+	return ok && rank >= roleRank[required]
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSignedToken(t *testing.T) {
+	userID := uuid.New()
+	valid, claims, err := MakeSignedToken(PurposeVerifyEmail, userID, "user@example.com", "mysecret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeSignedToken failed: %v", err)
+	}
+	expired, _, err := MakeSignedToken(PurposeVerifyEmail, userID, "user@example.com", "mysecret", -time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSignedToken failed: %v", err)
+	}
+
+	tests := []struct {
+		Name          string
+		Token         string
+		Purpose       string
+		Secret        string
+		ExpectedError bool
+	}{
+		{Name: "valid", Token: valid, Purpose: PurposeVerifyEmail, Secret: "mysecret", ExpectedError: false},
+		{Name: "wrong purpose", Token: valid, Purpose: PurposePasswordReset, Secret: "mysecret", ExpectedError: true},
+		{Name: "wrong secret", Token: valid, Purpose: PurposeVerifyEmail, Secret: "othersecret", ExpectedError: true},
+		{Name: "expired", Token: expired, Purpose: PurposeVerifyEmail, Secret: "mysecret", ExpectedError: true},
+		{Name: "tampered", Token: "x" + valid, Purpose: PurposeVerifyEmail, Secret: "mysecret", ExpectedError: true},
+		{Name: "malformed", Token: "not-a-token", Purpose: PurposeVerifyEmail, Secret: "mysecret", ExpectedError: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSignedToken(tt.Token, tt.Purpose, tt.Secret)
+		if tt.ExpectedError {
+			if err == nil {
+				t.Errorf("%s: expected error, got none", tt.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.Name, err)
+			continue
+		}
+		if got.UserID != userID || got.Email != "user@example.com" || got.Nonce != claims.Nonce {
+			t.Errorf("%s: parsed claims %+v do not match %+v", tt.Name, got, claims)
+		}
+	}
+}
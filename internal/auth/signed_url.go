@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidURLSignature = errors.New("invalid or expired link")
+
+func urlSignature(path string, expires int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("url\x00" + path + "\x00" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignURLPath returns the query values that let path be fetched without a
+// session until expires.
+func SignURLPath(path string, expires time.Time, secret string) url.Values {
+	unix := expires.Unix()
+	return url.Values{
+		"expires":   {strconv.FormatInt(unix, 10)},
+		"signature": {urlSignature(path, unix, secret)},
+	}
+}
+
+// VerifyURLPath checks the expires and signature values produced by
+// SignURLPath for path.
+func VerifyURLPath(path string, query url.Values, secret string, now time.Time) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidURLSignature
+	}
+	expected := urlSignature(path, expires, secret)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return ErrInvalidURLSignature
+	}
+	if now.Unix() > expires {
+		return ErrInvalidURLSignature
+	}
+	return nil
+}
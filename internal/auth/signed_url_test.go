@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignedURLPath(t *testing.T) {
+	now := time.Now()
+	valid := SignURLPath("/api/exports/1/download", now.Add(time.Minute), "secret")
+	expired := SignURLPath("/api/exports/1/download", now.Add(-time.Minute), "secret")
+	tampered := url.Values{"expires": {"9999999999"}, "signature": valid["signature"]}
+
+	tests := []struct {
+		Name          string
+		Path          string
+		Query         url.Values
+		Secret        string
+		ExpectedError error
+	}{
+		{Name: "valid", Path: "/api/exports/1/download", Query: valid, Secret: "secret", ExpectedError: nil},
+		{Name: "other path", Path: "/api/exports/2/download", Query: valid, Secret: "secret", ExpectedError: ErrInvalidURLSignature},
+		{Name: "wrong secret", Path: "/api/exports/1/download", Query: valid, Secret: "other", ExpectedError: ErrInvalidURLSignature},
+		{Name: "expired", Path: "/api/exports/1/download", Query: expired, Secret: "secret", ExpectedError: ErrInvalidURLSignature},
+		{Name: "extended expiry", Path: "/api/exports/1/download", Query: tampered, Secret: "secret", ExpectedError: ErrInvalidURLSignature},
+		{Name: "missing", Path: "/api/exports/1/download", Query: url.Values{}, Secret: "secret", ExpectedError: ErrInvalidURLSignature},
+	}
+
+	for _, tt := range tests {
+		err := VerifyURLPath(tt.Path, tt.Query, tt.Secret, now)
+		if !errors.Is(err, tt.ExpectedError) {
+			t.Errorf("%s: VerifyURLPath returned %v, expected %v", tt.Name, err, tt.ExpectedError)
+		}
+	}
+}
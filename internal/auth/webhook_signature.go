@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookTolerance is how far the timestamp of a signed webhook may be from
+// the receiver's clock. It bounds how long a captured request can be
+// replayed.
+const WebhookTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrWebhookTimestamp        = errors.New("webhook timestamp outside the allowed tolerance")
+)
+
+func webhookMAC(secret string, timestamp int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// SignWebhook returns a signature header of the form "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>".
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	unix := timestamp.Unix()
+	return "t=" + strconv.FormatInt(unix, 10) + ",v1=" + hex.EncodeToString(webhookMAC(secret, unix, body))
+}
+
+// VerifyWebhookSignature checks a header produced by SignWebhook against body.
+// Several v1 values are accepted so the sender can rotate secrets.
+func VerifyWebhookSignature(secret, header string, body []byte, now time.Time) error {
+	var timestamp int64 = -1
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidWebhookSignature
+			}
+			timestamp = unix
+		case "v1":
+			sig, err := hex.DecodeString(value)
+			if err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	if timestamp < 0 || len(signatures) == 0 {
+		return ErrInvalidWebhookSignature
+	}
+
+	expected := webhookMAC(secret, timestamp, body)
+	valid := false
+	for _, sig := range signatures {
+		if hmac.Equal(expected, sig) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidWebhookSignature
+	}
+
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > WebhookTolerance || skew < -WebhookTolerance {
+		return ErrWebhookTimestamp
+	}
+	return nil
+}
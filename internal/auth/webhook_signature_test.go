@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	header := SignWebhook("secret", now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", "secret", header, body, now, nil},
+		{"within tolerance", "secret", header, body, now.Add(WebhookTolerance), nil},
+		{"rotated secret", "secret", "v1=00ff," + header, body, now, nil},
+		{"wrong secret", "other", header, body, now, ErrInvalidWebhookSignature},
+		{"tampered body", "secret", header, []byte(`{"event":"user.upgraded"}`), now, ErrInvalidWebhookSignature},
+		{"too old", "secret", header, body, now.Add(WebhookTolerance + time.Second), ErrWebhookTimestamp},
+		{"from the future", "secret", header, body, now.Add(-WebhookTolerance - time.Second), ErrWebhookTimestamp},
+		{"missing timestamp", "secret", header[len("t=1700000000,"):], body, now, ErrInvalidWebhookSignature},
+		{"empty", "secret", "", body, now, ErrInvalidWebhookSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secret, tt.header, tt.body, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyWebhookSignature() error = %v, expected %v", err, tt.wantErr)
+			}
+		})
+	}
+}
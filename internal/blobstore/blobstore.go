@@ -0,0 +1,21 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files under opaque keys. Implementations must be
+// safe for concurrent use.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data io.Reader) error
+	// Get returns the blob stored under key, or ErrNotFound. The caller must
+	// close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing key is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
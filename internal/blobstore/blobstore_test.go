@@ -0,0 +1,58 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBlobStores(t *testing.T) {
+	stores := map[string]BlobStore{
+		"local":  NewLocalStore(t.TempDir()),
+		"memory": NewMemoryStore(),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := store.Put(ctx, "media/abc.png", strings.NewReader("first")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := store.Put(ctx, "media/abc.png", strings.NewReader("second")); err != nil {
+				t.Fatalf("Put overwrite failed: %v", err)
+			}
+
+			reader, err := store.Get(ctx, "media/abc.png")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			data, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil || string(data) != "second" {
+				t.Fatalf("Get returned %q (%v), expected %q", data, err, "second")
+			}
+
+			if err := store.Delete(ctx, "media/abc.png"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := store.Delete(ctx, "media/abc.png"); err != nil {
+				t.Fatalf("Delete of missing key failed: %v", err)
+			}
+			if _, err := store.Get(ctx, "media/abc.png"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after Delete returned %v, expected ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	for _, key := range []string{"", "../secret", "/etc/passwd", "media/../../x"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x")); err == nil {
+			t.Errorf("Put(%q) succeeded, expected an error", key)
+		}
+	}
+}
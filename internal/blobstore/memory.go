@@ -0,0 +1,58 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// MemoryStore keeps blobs in memory. Meant for development and tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, data io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = b
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
@@ -0,0 +1,109 @@
+package chirpstream
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeCreated = "chirp.created"
+	TypeDeleted = "chirp.deleted"
+	// TypeReset tells a resuming client that events it missed can no longer
+	// be replayed, so it should reload the timeline.
+	TypeReset = "stream.reset"
+
+	// Topic is the hub key chirp events are published under.
+	Topic = "chirps"
+
+	// MaxHashtags caps how many hashtags of a chirp are indexed.
+	MaxHashtags     = 10
+	maxHashtagChars = 50
+)
+
+var (
+	hashtagPattern = regexp.MustCompile(`#[A-Za-z0-9_]+`)
+	hashtagChars   = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// Event is a change to the public timeline. Payload is the chirp as the API
+// serves it for created events, and its id and author for deleted ones.
+type Event struct {
+	Seq      int64
+	Type     string
+	ChirpID  uuid.UUID
+	UserID   uuid.UUID
+	Hashtags []string
+	Payload  []byte
+}
+
+// Filter selects the events a stream receives.
+type Filter struct {
+	AuthorID uuid.NullUUID
+	Hashtag  string
+	// Excluded authors are never streamed, for example because the viewer
+	// blocked them.
+	Excluded map[uuid.UUID]bool
+}
+
+// Matches reports whether e passes the filter. Deletions ignore the hashtag
+// filter: the deleted chirp's body is gone, and a client can drop ids it
+// never saw.
+func (f Filter) Matches(e Event) bool {
+	if f.Excluded[e.UserID] {
+		return false
+	}
+	if f.AuthorID.Valid && f.AuthorID.UUID != e.UserID {
+		return false
+	}
+	if f.Hashtag == "" || e.Type == TypeDeleted {
+		return true
+	}
+	for _, tag := range e.Hashtags {
+		if tag == f.Hashtag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeHashtag validates a hashtag given with or without its "#" and
+// returns it in the form ExtractHashtags produces.
+func NormalizeHashtag(tag string) (string, error) {
+	tag = strings.TrimPrefix(tag, "#")
+	if tag == "" || len(tag) > maxHashtagChars {
+		return "", errors.New("hashtag must be between 1 and 50 characters")
+	}
+	if !hashtagChars.MatchString(tag) {
+		return "", errors.New("hashtag can only contain letters, digits and underscores")
+	}
+	return strings.ToLower(tag), nil
+}
+
+// ExtractHashtags returns the hashtags in body, lowercased and without
+// duplicates. "#" inside a word is not a hashtag.
+func ExtractHashtags(body string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, loc := range hashtagPattern.FindAllStringIndex(body, -1) {
+		if loc[0] > 0 && isTagChar(body[loc[0]-1]) {
+			continue
+		}
+		tag := strings.ToLower(body[loc[0]+1 : loc[1]])
+		if len(tag) > maxHashtagChars || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == MaxHashtags {
+			break
+		}
+	}
+	return tags
+}
+
+func isTagChar(c byte) bool {
+	return c == '_' || c == '#' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
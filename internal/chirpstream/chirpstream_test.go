@@ -0,0 +1,88 @@
+package chirpstream
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "hello world", []string{}},
+		{"single", "loving #Go today", []string{"go"}},
+		{"punctuation", "#one,#two! (#three)", []string{"one", "two", "three"}},
+		{"duplicates", "#Go #go #GO", []string{"go"}},
+		{"inside word", "issue a#1 and ##double", []string{}},
+		{"too long", "#" + strings.Repeat("x", 51), []string{}},
+		{"capped", "#a #b #c #d #e #f #g #h #i #j #k", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractHashtags(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractHashtags(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{"golang", "golang", false},
+		{"#GoLang", "golang", false},
+		{"snake_case", "snake_case", false},
+		{"", "", true},
+		{"#", "", true},
+		{"two words", "", true},
+		{"dash-ed", "", true},
+		{strings.Repeat("x", 51), "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeHashtag(tt.tag)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeHashtag(%q) = %q, %v; want %q, error %v", tt.tag, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	created := Event{Type: TypeCreated, UserID: alice, Hashtags: []string{"go", "chirpy"}}
+	deleted := Event{Type: TypeDeleted, UserID: alice}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{"no filter", Filter{}, created, true},
+		{"author", Filter{AuthorID: uuid.NullUUID{UUID: alice, Valid: true}}, created, true},
+		{"other author", Filter{AuthorID: uuid.NullUUID{UUID: bob, Valid: true}}, created, false},
+		{"hashtag", Filter{Hashtag: "chirpy"}, created, true},
+		{"other hashtag", Filter{Hashtag: "rust"}, created, false},
+		{"deletion ignores hashtag", Filter{Hashtag: "rust"}, deleted, true},
+		{"deletion of other author", Filter{AuthorID: uuid.NullUUID{UUID: bob, Valid: true}}, deleted, false},
+		{"excluded", Filter{Excluded: map[uuid.UUID]bool{alice: true}}, created, false},
+		{"excluded deletion", Filter{Excluded: map[uuid.UUID]bool{alice: true}}, deleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.event); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,33 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/audit"
 	"github.com/leonardoklaser/Chirpy/internal/auth"
+	"github.com/leonardoklaser/Chirpy/internal/blobstore"
+	"github.com/leonardoklaser/Chirpy/internal/chirpstream"
 	"github.com/leonardoklaser/Chirpy/internal/database"
+	"github.com/leonardoklaser/Chirpy/internal/entitlements"
+	"github.com/leonardoklaser/Chirpy/internal/jobs"
+	"github.com/leonardoklaser/Chirpy/internal/mailer"
+	"github.com/leonardoklaser/Chirpy/internal/media"
+	"github.com/leonardoklaser/Chirpy/internal/outbound"
+	"github.com/leonardoklaser/Chirpy/internal/pubsub"
+	"github.com/leonardoklaser/Chirpy/models"
 	"github.com/leonardoklaser/Chirpy/utils"
 )
 
@@ -18,25 +35,184 @@ type contextKey string
 
 const UserIDKey contextKey = "userID"
 const TokenKey contextKey = "Token"
+const RoleKey contextKey = "role"
+const RequestIDKey contextKey = "requestID"
+
+// MaxWebhookBodyBytes caps the size of incoming webhook bodies.
+const MaxWebhookBodyBytes = 1 << 20
 
 type ApiConfig struct {
 	Environment       string
 	FileServerHits *atomic.Int32
+	Conn           *sql.DB
+	// DatabaseURL is kept for connections database/sql can't pool, such as
+	// LISTEN.
+	DatabaseURL    string
 	DB             *database.Queries
+	Audit          *audit.Recorder
 	SecretKey      string
 	PolkaKey       string
+	// PolkaWebhookSecret signs Polka webhook bodies.
+	PolkaWebhookSecret string
+	Hasher         auth.PasswordHasher
+	Lockout        auth.LockoutPolicy
+	PasswordPolicy auth.PasswordPolicy
+	Mailer         mailer.Mailer
+	BaseURL        string
+	// UnverifiedChirpLimit is how many chirps an account may post before
+	// its email is verified. A negative value disables the limit.
+	UnverifiedChirpLimit int
+	// AccountDeletionGrace is how long a deleted account can still be
+	// restored by logging in before it is purged.
+	AccountDeletionGrace time.Duration
+	ExportDir            string
+	Blobs                blobstore.BlobStore
+	MediaLimits          media.Limits
+	// Entitlements are the limits and features of free users and Chirpy Red
+	// members, read from ENTITLEMENTS_FILE.
+	Entitlements entitlements.Tiers
+	// WebhookSender delivers outbound webhooks. WebhookAllowInsecure lets
+	// endpoints use plain HTTP and private addresses, for local development.
+	WebhookSender        *outbound.Sender
+	WebhookAllowInsecure bool
+	// Jobs enqueues background jobs, which JobWorkers workers run.
+	Jobs       *jobs.Client
+	JobWorkers int
+	// Notifications pushes new notifications to the streams their recipient
+	// has open on this instance.
+	Notifications *pubsub.Hub[uuid.UUID, models.Notification]
+	// ChirpEvents relays the live chirp stream to the streams open on this
+	// instance.
+	ChirpEvents *pubsub.Hub[string, chirpstream.Event]
+	// Messages pushes new direct messages to the streams their recipients
+	// have open on this instance.
+	Messages *pubsub.Hub[uuid.UUID, models.Message]
 }
 
 var instance *ApiConfig
 
-func createDatabaseInstance() (*database.Queries, error) {
+func createDatabaseInstance() (*sql.DB, error) {
 	dbURL := os.Getenv("DB_URL")
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		return &database.Queries{}, fmt.Errorf("error opening database connection: %s", err.Error())
+		return nil, fmt.Errorf("error opening database connection: %s", err.Error())
+	}
+
+	return db, nil
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func envIntAllowZero(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
 	}
+	return fallback
+}
+
+func mailerFromEnv() mailer.Mailer {
+	from := envString("MAIL_FROM", "Chirpy <no-reply@chirpy.local>")
+	if os.Getenv("MAILER") == "smtp" {
+		return mailer.NewSMTPMailer(os.Getenv("SMTP_HOST"), envInt("SMTP_PORT", 587), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	}
+	return mailer.NewOutboxMailer(envString("MAIL_OUTBOX_DIR", "outbox"), from)
+}
+
+func blobStoreFromEnv() blobstore.BlobStore {
+	if os.Getenv("MEDIA_STORE") == "memory" {
+		return blobstore.NewMemoryStore()
+	}
+	return blobstore.NewLocalStore(envString("MEDIA_DIR", "media"))
+}
+
+func mediaLimitsFromEnv() media.Limits {
+	limits := media.DefaultLimits
+	limits.MaxBytes = int64(envInt("MEDIA_MAX_BYTES", int(limits.MaxBytes)))
+	limits.MaxPixels = envInt("MEDIA_MAX_PIXELS", limits.MaxPixels)
+	limits.MaxFrames = envInt("MEDIA_MAX_FRAMES", limits.MaxFrames)
+	return limits
+}
 
-	return database.New(db), nil
+// entitlementsFromEnv loads the tiers from ENTITLEMENTS_FILE. A broken file
+// stops the server rather than silently granting the default tiers.
+func entitlementsFromEnv() entitlements.Tiers {
+	tiers, err := entitlements.Load(os.Getenv("ENTITLEMENTS_FILE"))
+	if err != nil {
+		log.Fatalf("Error loading entitlements: %v", err)
+	}
+	return tiers
+}
+
+// polkaWebhookSecretFromEnv reads the secret that signs Polka webhooks. The
+// server refuses to start without one, since the API key alone can be
+// replayed.
+func polkaWebhookSecretFromEnv() string {
+	secret := os.Getenv("POLKA_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Fatalf("POLKA_WEBHOOK_SECRET must be set")
+	}
+	return secret
+}
+
+// argon2ParamsFromEnv reads the password hashing cost. Invalid values stop
+// the server, since they would only fail at the first signup or login.
+func argon2ParamsFromEnv() auth.Argon2Params {
+	params := auth.DefaultArgon2Params
+	memory := argon2EnvInt("ARGON2_MEMORY_KB", int(params.Memory))
+	iterations := argon2EnvInt("ARGON2_ITERATIONS", int(params.Iterations))
+	parallelism := argon2EnvInt("ARGON2_PARALLELISM", int(params.Parallelism))
+	// Check the range before converting, so large values can't wrap around.
+	if memory < 0 || memory > math.MaxUint32 || iterations < 0 || iterations > math.MaxUint32 || parallelism < 0 || parallelism > math.MaxUint8 {
+		log.Fatalf("Invalid argon2 parameters: m=%d, t=%d, p=%d", memory, iterations, parallelism)
+	}
+	params.Memory = uint32(memory)
+	params.Iterations = uint32(iterations)
+	params.Parallelism = uint8(parallelism)
+	if err := params.Validate(); err != nil {
+		log.Fatalf("Invalid argon2 parameters: %v", err)
+	}
+	return params
+}
+
+func argon2EnvInt(name string, fallback int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", name, raw, err)
+	}
+	return value
+}
+
+func lockoutPolicyFromEnv() auth.LockoutPolicy {
+	policy := auth.DefaultLockoutPolicy
+	policy.Threshold = envInt("LOGIN_LOCKOUT_THRESHOLD", policy.Threshold)
+	policy.BaseDelay = time.Duration(envInt("LOGIN_LOCKOUT_BASE_SECONDS", int(policy.BaseDelay/time.Second))) * time.Second
+	policy.MaxDelay = time.Duration(envInt("LOGIN_LOCKOUT_MAX_SECONDS", int(policy.MaxDelay/time.Second))) * time.Second
+	return policy
+}
+
+func passwordPolicyFromEnv() auth.PasswordPolicy {
+	policy := auth.DefaultPasswordPolicy
+	policy.MinLength = envInt("PASSWORD_MIN_LENGTH", policy.MinLength)
+	policy.MinClasses = envIntAllowZero("PASSWORD_MIN_CLASSES", policy.MinClasses)
+	return policy
 }
 
 func New() (*ApiConfig, error) {
@@ -46,12 +222,35 @@ func New() (*ApiConfig, error) {
 			return &ApiConfig{}, nil
 		}
 
+		allowInsecureWebhooks := os.Getenv("WEBHOOK_ALLOW_INSECURE") == "true"
 		instance = &ApiConfig{
 			Environment:       os.Getenv("PLATFORM"),
 			FileServerHits: &atomic.Int32{},
-			DB:             db,
+			Conn:           db,
+			DatabaseURL:    os.Getenv("DB_URL"),
+			DB:             database.New(db),
+			Audit:          audit.NewRecorder(db),
 			SecretKey:      os.Getenv("APP_SECRET"),
 			PolkaKey:       os.Getenv("POLKA_KEY"),
+			PolkaWebhookSecret: polkaWebhookSecretFromEnv(),
+			Hasher:         auth.NewArgon2Hasher(argon2ParamsFromEnv()),
+			Lockout:        lockoutPolicyFromEnv(),
+			PasswordPolicy: passwordPolicyFromEnv(),
+			Mailer:         mailerFromEnv(),
+			BaseURL:        envString("BASE_URL", "http://localhost:8080"),
+			UnverifiedChirpLimit: envIntAllowZero("UNVERIFIED_CHIRP_LIMIT", 5),
+			AccountDeletionGrace: time.Duration(envIntAllowZero("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour,
+			ExportDir:            envString("EXPORT_DIR", "exports"),
+			Blobs:                blobStoreFromEnv(),
+			MediaLimits:          mediaLimitsFromEnv(),
+			Entitlements:         entitlementsFromEnv(),
+			WebhookSender:        outbound.NewSender(allowInsecureWebhooks),
+			WebhookAllowInsecure: allowInsecureWebhooks,
+			Jobs:                 jobs.NewClient(jobs.NewPostgresStore(database.New(db))),
+			JobWorkers:           envInt("JOB_WORKERS", 4),
+			Notifications:        pubsub.NewHub[uuid.UUID, models.Notification](),
+			ChirpEvents:          pubsub.NewHub[string, chirpstream.Event](),
+			Messages:             pubsub.NewHub[uuid.UUID, models.Message](),
 		}
 		instance.FileServerHits.Store(0)
 	}
@@ -59,7 +258,16 @@ func New() (*ApiConfig, error) {
 	return instance, nil
 }
 
+// MiddlewareAuth authenticates the request with a first party JWT. Personal
+// access tokens and OAuth access tokens are refused; routes that accept them
+// use MiddlewareAuthScope.
 func (cfg *ApiConfig) MiddlewareAuth(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.MiddlewareAuthScope("", next)
+}
+
+// MiddlewareAuthScope authenticates the request with a first party JWT, or
+// with a personal access token or OAuth access token carrying scope.
+func (cfg *ApiConfig) MiddlewareAuthScope(scope string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 		token, err := auth.GetBearerToken(&req.Header)
 		if err != nil {
@@ -67,18 +275,94 @@ func (cfg *ApiConfig) MiddlewareAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		userId, err := auth.ValidateJWT(token, cfg.SecretKey)
-		if err != nil {
-			utils.RespondWithError(resp, http.StatusUnauthorized, "Unauthorized")
+		ctx, status, message := cfg.authenticate(req, token, scope)
+		if status != 0 {
+			utils.RespondWithError(resp, status, message)
 			return
 		}
-		ctx := context.WithValue(req.Context(), UserIDKey, userId)
-		ctx = context.WithValue(ctx, TokenKey, token)
 
 		next.ServeHTTP(resp, req.WithContext(ctx))
 	})
 }
 
+// MiddlewareOptionalAuthScope authenticates the request like
+// MiddlewareAuthScope when it carries an Authorization header, and lets
+// anonymous requests through without a user in the context.
+func (cfg *ApiConfig) MiddlewareOptionalAuthScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") == "" {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		cfg.MiddlewareAuthScope(scope, next).ServeHTTP(resp, req)
+	})
+}
+
+// authenticate resolves token to a user and returns a context carrying the
+// user, token and role. A non zero status means the request must be refused.
+func (cfg *ApiConfig) authenticate(req *http.Request, token, scope string) (context.Context, int, string) {
+	var userId uuid.UUID
+	if auth.IsPersonalAccessToken(token) {
+		pat, err := cfg.DB.GetValidPersonalAccessToken(req.Context(), auth.HashToken(token))
+		if err != nil {
+			return nil, http.StatusUnauthorized, "Unauthorized"
+		}
+		if scope == "" {
+			return nil, http.StatusForbidden, "Personal access tokens are not accepted on this endpoint"
+		}
+		if !auth.HasScope(pat.Scopes, scope) {
+			return nil, http.StatusForbidden, fmt.Sprintf("Token is missing required scope %q", scope)
+		}
+		if err := cfg.DB.TouchPersonalAccessToken(req.Context(), pat.ID); err != nil {
+			log.Printf("Error updating personal access token %s: %v", pat.ID, err)
+		}
+		userId = pat.UserID
+	} else {
+		var scopes []string
+		var err error
+		userId, scopes, err = auth.ValidateScopedJWT(token, cfg.SecretKey)
+		if err != nil {
+			return nil, http.StatusUnauthorized, "Unauthorized"
+		}
+		// OAuth access tokens are limited to the scopes the user consented to.
+		if scopes != nil && (scope == "" || !auth.HasScope(scopes, scope)) {
+			return nil, http.StatusForbidden, "Token is not allowed on this endpoint"
+		}
+	}
+	state, err := cfg.DB.GetUserAuthState(req.Context(), userId)
+	if err != nil {
+		return nil, http.StatusUnauthorized, "Unauthorized"
+	}
+	if state.SuspendedAt.Valid {
+		return nil, http.StatusForbidden, "Account suspended"
+	}
+	if state.DeletionPending {
+		return nil, http.StatusForbidden, "Account is scheduled for deletion, log in again to restore it"
+	}
+
+	ctx := context.WithValue(req.Context(), UserIDKey, userId)
+	ctx = context.WithValue(ctx, TokenKey, token)
+	ctx = context.WithValue(ctx, RoleKey, state.Role)
+	return ctx, 0, ""
+}
+
+// RequireRole only lets through users whose role is at least role. It must be
+// wrapped by MiddlewareAuth, which puts the role in the request context.
+func (cfg *ApiConfig) RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		userRole, ok := req.Context().Value(RoleKey).(string)
+		if !ok || !auth.RoleAtLeast(userRole, role) {
+			utils.RespondWithError(resp, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		next.ServeHTTP(resp, req)
+	})
+}
+
+// MiddlewarePolka checks the Polka API key and the X-Polka-Signature HMAC of
+// the body. The signature carries a timestamp, which stops old requests from
+// being replayed.
 func (cfg *ApiConfig) MiddlewarePolka(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 		APIKey, err := auth.GetAPIKey(&req.Header)
@@ -87,15 +371,54 @@ func (cfg *ApiConfig) MiddlewarePolka(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		if cfg.PolkaKey != APIKey {
+		if subtle.ConstantTimeCompare([]byte(cfg.PolkaKey), []byte(APIKey)) != 1 {
 			utils.RespondWithError(resp, http.StatusUnauthorized, "Unauthorized")
 			return
 		}
 
+		body, err := io.ReadAll(http.MaxBytesReader(resp, req.Body, MaxWebhookBodyBytes))
+		if err != nil {
+			utils.RespondWithError(resp, http.StatusRequestEntityTooLarge, "Webhook body is too large")
+			return
+		}
+		err = auth.VerifyWebhookSignature(cfg.PolkaWebhookSecret, req.Header.Get("X-Polka-Signature"), body, time.Now())
+		if err != nil {
+			utils.RespondWithError(resp, http.StatusUnauthorized, err.Error())
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
 		next.ServeHTTP(resp, req)
 	})
 }
 
+// MiddlewareRequestID tags every request with an ID, reusing a well-formed
+// X-Request-ID header from the client or proxy when there is one.
+func (cfg *ApiConfig) MiddlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		resp.Header().Set("X-Request-ID", requestID)
+
+		ctx := context.WithValue(req.Context(), RequestIDKey, requestID)
+		next.ServeHTTP(resp, req.WithContext(ctx))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c == '-' || c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
 func (cfg *ApiConfig) HandleReset() http.HandlerFunc {
 	return http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
 		if cfg.Environment != "dev" {
@@ -8,25 +8,62 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const countChirpsByUserId = `-- name: CountChirpsByUserId :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1
+`
+
+func (q *Queries) CountChirpsByUserId(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUserId, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countChirpsSince = `-- name: CountChirpsSince :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND created_at > $2
+`
+
+type CountChirpsSinceParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+func (q *Queries) CountChirpsSince(ctx context.Context, arg CountChirpsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsSince, arg.UserID, arg.Since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	Status    string
+	PublishAt sql.NullTime
+	ReplyToID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+		arg.PublishAt,
+		arg.ReplyToID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -34,6 +71,11 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.EditedAt,
+		&i.ReplyToID,
 	)
 	return i, err
 }
@@ -46,12 +88,53 @@ func (q *Queries) DeleteChirpById(ctx context.Context, id uuid.UUID) (sql.Result
 	return q.db.ExecContext(ctx, deleteChirpById, id)
 }
 
+const editPublishedChirp = `-- name: EditPublishedChirp :one
+UPDATE chirps SET body = $3, edited_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status = 'published' AND hidden_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id
+`
+
+type EditPublishedChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Body   string
+}
+
+// A chirp hidden by a moderator can't be edited back into view.
+func (q *Queries) EditPublishedChirp(ctx context.Context, arg EditPublishedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editPublishedChirp, arg.ID, arg.UserID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.EditedAt,
+		&i.ReplyToID,
+	)
+	return i, err
+}
+
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id FROM chirps
+WHERE ((hidden_at IS NULL AND status = 'published') OR user_id = $1)
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $1)
+       OR (blocker_id = $1 AND blocked_id = chirps.user_id)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE muter_id = $1 AND muted_id = chirps.user_id
+  )
+ORDER BY created_at
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +148,11 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.EditedAt,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -80,7 +168,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -92,16 +180,235 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.EditedAt,
+		&i.ReplyToID,
 	)
 	return i, err
 }
 
 const getChirpsByUserId = `-- name: GetChirpsByUserId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at, chirps.status, chirps.publish_at, chirps.edited_at, chirps.reply_to_id, (pinned_chirps.chirp_id IS NOT NULL)::boolean AS pinned
+FROM chirps
+LEFT JOIN pinned_chirps ON pinned_chirps.chirp_id = chirps.id
+WHERE chirps.user_id = $1
+  AND ((chirps.hidden_at IS NULL AND chirps.status = 'published') OR chirps.user_id = $2)
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = chirps.user_id)
+  )
+ORDER BY pinned DESC, chirps.created_at
+`
+
+type GetChirpsByUserIdParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+type GetChirpsByUserIdRow struct {
+	Chirp  Chirp
+	Pinned bool
+}
+
+// Lists the chirps and replies of a user, their pinned chirp first.
+func (q *Queries) GetChirpsByUserId(ctx context.Context, arg GetChirpsByUserIdParams) ([]GetChirpsByUserIdRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserId, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsByUserIdRow
+	for rows.Next() {
+		var i GetChirpsByUserIdRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.HiddenAt,
+			&i.Chirp.Status,
+			&i.Chirp.PublishAt,
+			&i.Chirp.EditedAt,
+			&i.Chirp.ReplyToID,
+			&i.Pinned,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByUserIdWithoutReplies = `-- name: GetChirpsByUserIdWithoutReplies :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at, chirps.status, chirps.publish_at, chirps.edited_at, chirps.reply_to_id, (pinned_chirps.chirp_id IS NOT NULL)::boolean AS pinned
+FROM chirps
+LEFT JOIN pinned_chirps ON pinned_chirps.chirp_id = chirps.id
+WHERE chirps.user_id = $1 AND chirps.reply_to_id IS NULL
+  AND ((chirps.hidden_at IS NULL AND chirps.status = 'published') OR chirps.user_id = $2)
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = chirps.user_id)
+  )
+ORDER BY pinned DESC, chirps.created_at
+`
+
+type GetChirpsByUserIdWithoutRepliesParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+type GetChirpsByUserIdWithoutRepliesRow struct {
+	Chirp  Chirp
+	Pinned bool
+}
+
+// Lists the chirps of a user, leaving out their replies, their pinned chirp
+// first.
+func (q *Queries) GetChirpsByUserIdWithoutReplies(ctx context.Context, arg GetChirpsByUserIdWithoutRepliesParams) ([]GetChirpsByUserIdWithoutRepliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserIdWithoutReplies, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsByUserIdWithoutRepliesRow
+	for rows.Next() {
+		var i GetChirpsByUserIdWithoutRepliesRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.HiddenAt,
+			&i.Chirp.Status,
+			&i.Chirp.PublishAt,
+			&i.Chirp.EditedAt,
+			&i.Chirp.ReplyToID,
+			&i.Pinned,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMediaChirpsByUserId = `-- name: GetMediaChirpsByUserId :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id FROM chirps
+WHERE user_id = $1 AND ((hidden_at IS NULL AND status = 'published') OR user_id = $2)
+  AND EXISTS (
+    SELECT 1 FROM media_attachments WHERE media_attachments.chirp_id = chirps.id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = chirps.user_id)
+  )
+ORDER BY created_at
+`
+
+type GetMediaChirpsByUserIdParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+// Lists the chirps of a user that have media attached.
+func (q *Queries) GetMediaChirpsByUserId(ctx context.Context, arg GetMediaChirpsByUserIdParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getMediaChirpsByUserId, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.EditedAt,
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirpById = `-- name: GetVisibleChirpById :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id FROM chirps
+WHERE id = $1 AND ((hidden_at IS NULL AND status = 'published') OR user_id = $2)
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = chirps.user_id AND blocked_id = $2
+  )
 `
 
-func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserId, userID)
+type GetVisibleChirpByIdParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetVisibleChirpById(ctx context.Context, arg GetVisibleChirpByIdParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirpById, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.EditedAt,
+		&i.ReplyToID,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :execresult
+UPDATE chirps SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (sql.Result, error) {
+	return q.db.ExecContext(ctx, hideChirp, id)
+}
+
+const listUnpublishedChirps = `-- name: ListUnpublishedChirps :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id FROM chirps WHERE user_id = $1 AND status <> 'published' ORDER BY updated_at DESC
+`
+
+func (q *Queries) ListUnpublishedChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listUnpublishedChirps, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +422,11 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.EditedAt,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -128,3 +440,112 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const publishChirp = `-- name: PublishChirp :one
+UPDATE chirps SET status = 'published', publish_at = NULL, created_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status <> 'published'
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id
+`
+
+type PublishChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) PublishChirp(ctx context.Context, arg PublishChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishChirp, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.EditedAt,
+		&i.ReplyToID,
+	)
+	return i, err
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps SET status = 'published', created_at = publish_at, publish_at = NULL, updated_at = NOW()
+WHERE status = 'scheduled' AND publish_at <= NOW()
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id
+`
+
+// Publishes scheduled chirps whose time has come. created_at becomes the
+// scheduled time, so chirps published late after downtime keep their place.
+func (q *Queries) PublishDueChirps(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.EditedAt,
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUnpublishedChirp = `-- name: UpdateUnpublishedChirp :one
+UPDATE chirps SET body = $3, status = $4, publish_at = $5, updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status <> 'published'
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, status, publish_at, edited_at, reply_to_id
+`
+
+type UpdateUnpublishedChirpParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	Status    string
+	PublishAt sql.NullTime
+}
+
+func (q *Queries) UpdateUnpublishedChirp(ctx context.Context, arg UpdateUnpublishedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateUnpublishedChirp,
+		arg.ID,
+		arg.UserID,
+		arg.Body,
+		arg.Status,
+		arg.PublishAt,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.EditedAt,
+		&i.ReplyToID,
+	)
+	return i, err
+}
@@ -0,0 +1,13 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// IsUniqueViolation reports whether err was caused by a unique constraint.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
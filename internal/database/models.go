@@ -6,17 +6,262 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountDeletion struct {
+	UserID      uuid.UUID
+	RequestedAt time.Time
+	PurgeAfter  time.Time
+}
+
+type AdminAction struct {
+	ID         uuid.UUID
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Details    json.RawMessage
+	CreatedAt  time.Time
+}
+
+type AuditEvent struct {
+	Seq        int64
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Ip         string
+	RequestID  string
+	Details    json.RawMessage
+	PrevHash   string
+	Hash       string
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	HiddenAt  sql.NullTime
+	Status    string
+	PublishAt sql.NullTime
+	EditedAt  sql.NullTime
+	ReplyToID uuid.NullUUID
+}
+
+type ChirpEvent struct {
+	Seq       int64
+	Type      string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Hashtags  []string
+	Payload   string
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedBy uuid.NullUUID
+	IsGroup   bool
+	DirectKey sql.NullString
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type DataExport struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Status      string
+	FilePath    string
+	Error       string
+	CreatedAt   time.Time
+	CompletedAt sql.NullTime
+	ExpiresAt   sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     string
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	UniqueKey   sql.NullString
+	LastError   string
+	LockedUntil sql.NullTime
+	CreatedAt   time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type List struct {
+	ID          uuid.UUID
+	OwnerID     uuid.UUID
+	Name        string
+	Description string
+	IsPrivate   bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type MediaAttachment struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	ChirpID       uuid.NullUUID
+	Position      int32
+	ContentType   string
+	SizeBytes     int32
+	Width         int32
+	Height        int32
+	StorageKey    string
+	ThumbnailKey  string
+	ThumbnailType string
+	CreatedAt     time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type MessagingSetting struct {
+	UserID   uuid.UUID
+	DmPolicy string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Type      string
+	ActorID   uuid.NullUUID
+	ChirpID   uuid.NullUUID
+	CreatedAt time.Time
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	SecretHash   sql.NullString
+	Name         string
+	RedirectUris []string
+	OwnerID      uuid.UUID
+	CreatedAt    time.Time
+}
+
+type OauthConsent struct {
+	UserID    uuid.UUID
+	ClientID  string
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+	ClosedAt  sql.NullTime
+	CreatedAt time.Time
+}
+
+type PollOption struct {
+	ChirpID  uuid.UUID
+	Position int32
+	Label    string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Position  int32
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -26,13 +271,109 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 	ExpiresAt sql.NullTime
 	RevokedAt sql.NullTime
+	ClientID  sql.NullString
+	Scopes    []string
+}
+
+type Report struct {
+	ID             uuid.UUID
+	ReporterID     uuid.UUID
+	TargetType     string
+	TargetID       uuid.UUID
+	ReportedUserID uuid.UUID
+	Reason         string
+	Details        string
+	Status         string
+	ClaimedBy      uuid.NullUUID
+	ClaimedAt      sql.NullTime
+	ResolvedBy     uuid.NullUUID
+	ResolvedAt     sql.NullTime
+	Resolution     sql.NullString
+	ResolutionNote string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	Status           string
+	CurrentPeriodEnd sql.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type SubscriptionEvent struct {
+	ID               int64
+	UserID           uuid.UUID
+	EventType        string
+	OldStatus        string
+	NewStatus        string
+	CurrentPeriodEnd sql.NullTime
+	WebhookEventID   string
+	CreatedAt        time.Time
 }
 
 type User struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Email       string
-	Password    string
-	IsChirpyRed sql.NullBool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	Password            string
+	FailedLoginAttempts int32
+	LockedUntil         sql.NullTime
+	EmailVerified       bool
+	Role                string
+	SuspendedAt         sql.NullTime
+	SuspendedReason     sql.NullString
+	Handle              sql.NullString
+	DisplayName         string
+	Bio                 string
+	Location            string
+	Website             string
+	AvatarUrl           string
+}
+
+type UserToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	Email     string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode int32
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	OwnerID   uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+type WebhookEvent struct {
+	Source      string
+	ID          string
+	EventType   string
+	Payload     string
+	Status      string
+	Error       string
+	Attempts    int32
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
 }
@@ -8,8 +8,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
@@ -17,7 +19,7 @@ INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
 VALUES (
     $1, NOW(), NOW(), $2, $3
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
@@ -36,17 +38,20 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
 const getUserForValidRefreshToken = `-- name: GetUserForValidRefreshToken :one
-SELECT u.id, u.created_at, u.updated_at, u.email, u.password, u.is_chirpy_red
+SELECT u.id, u.created_at, u.updated_at, u.email, u.password, u.failed_login_attempts, u.locked_until, u.email_verified, u.role, u.suspended_at, u.suspended_reason, u.handle, u.display_name, u.bio, u.location, u.website, u.avatar_url
 FROM users u
 INNER JOIN refresh_tokens rt ON u.id = rt.user_id
 WHERE rt.token = $1
   AND rt.expires_at > NOW()      
   AND rt.revoked_at IS NULL
+  AND rt.client_id IS NULL
 `
 
 func (q *Queries) GetUserForValidRefreshToken(ctx context.Context, token string) (User, error) {
@@ -58,13 +63,24 @@ func (q *Queries) GetUserForValidRefreshToken(ctx context.Context, token string)
 		&i.UpdatedAt,
 		&i.Email,
 		&i.Password,
-		&i.IsChirpyRed,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
 	)
 	return i, err
 }
 
 const getValidRefreshToken = `-- name: GetValidRefreshToken :one
-SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token = $1 AND expires_at > NOW() AND revoked_at IS NULL)
+SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token = $1 AND expires_at > NOW() AND revoked_at IS NULL AND client_id IS NULL)
 `
 
 func (q *Queries) GetValidRefreshToken(ctx context.Context, token string) (bool, error) {
@@ -74,6 +90,59 @@ func (q *Queries) GetValidRefreshToken(ctx context.Context, token string) (bool,
 	return exists, err
 }
 
+const listSessionsForUser = `-- name: ListSessionsForUser :many
+SELECT created_at, updated_at, expires_at, revoked_at, client_id, scopes
+FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at DESC
+`
+
+type ListSessionsForUserRow struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt sql.NullTime
+	RevokedAt sql.NullTime
+	ClientID  sql.NullString
+	Scopes    []string
+}
+
+func (q *Queries) ListSessionsForUser(ctx context.Context, userID uuid.UUID) ([]ListSessionsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSessionsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsForUserRow
+	for rows.Next() {
+		var i ListSessionsForUserRow
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, userID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :execresult
 UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1
 `
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -18,7 +19,7 @@ INSERT INTO users (id, created_at, updated_at, email, password)
 VALUES (
     gen_random_uuid(), NOW(), NOW(), $1, $2
 )
-RETURNING id, created_at, updated_at, email, password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, password, failed_login_attempts, locked_until, email_verified, role, suspended_at, suspended_reason, handle, display_name, bio, location, website, avatar_url
 `
 
 type CreateUserParams struct {
@@ -35,11 +36,30 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.Password,
-		&i.IsChirpyRed,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
 	)
 	return i, err
 }
 
+const deleteUserById = `-- name: DeleteUserById :execresult
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUserById(ctx context.Context, id uuid.UUID) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteUserById, id)
+}
+
 const deleteUsers = `-- name: DeleteUsers :execresult
 TRUNCATE TABLE users CASCADE
 `
@@ -48,17 +68,91 @@ func (q *Queries) DeleteUsers(ctx context.Context) (sql.Result, error) {
 	return q.db.ExecContext(ctx, deleteUsers)
 }
 
+const getPublicProfile = `-- name: GetPublicProfile :one
+SELECT u.id, u.created_at, u.handle, u.display_name, u.bio, u.location, u.website, u.avatar_url,
+    EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = u.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red,
+    (SELECT COUNT(*) FROM follows WHERE followee_id = u.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = u.id) AS following_count,
+    (SELECT COUNT(*) FROM chirps WHERE user_id = u.id AND hidden_at IS NULL AND status = 'published') AS chirp_count
+FROM users u
+WHERE u.id = $1 OR lower(u.handle) = lower($2)
+`
+
+type GetPublicProfileParams struct {
+	ID     uuid.NullUUID
+	Handle sql.NullString
+}
+
+type GetPublicProfileRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
+	Website        string
+	AvatarUrl      string
+	IsChirpyRed    bool
+	FollowerCount  int64
+	FollowingCount int64
+	ChirpCount     int64
+}
+
+func (q *Queries) GetPublicProfile(ctx context.Context, arg GetPublicProfileParams) (GetPublicProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getPublicProfile, arg.ID, arg.Handle)
+	var i GetPublicProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsChirpyRed,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.ChirpCount,
+	)
+	return i, err
+}
+
+const getUserAuthState = `-- name: GetUserAuthState :one
+SELECT role, suspended_at, EXISTS (SELECT 1 FROM account_deletions WHERE user_id = users.id) AS deletion_pending
+FROM users WHERE id = $1
+`
+
+type GetUserAuthStateRow struct {
+	Role            string
+	SuspendedAt     sql.NullTime
+	DeletionPending bool
+}
+
+func (q *Queries) GetUserAuthState(ctx context.Context, id uuid.UUID) (GetUserAuthStateRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserAuthState, id)
+	var i GetUserAuthStateRow
+	err := row.Scan(&i.Role, &i.SuspendedAt, &i.DeletionPending)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, is_chirpy_red ,updated_at, email, password FROM users WHERE email = $1
+SELECT id, created_at, EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = users.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red, updated_at, email, password, failed_login_attempts, locked_until, email_verified, role, suspended_at, suspended_reason FROM users WHERE email = $1
 `
 
 type GetUserByEmailRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	IsChirpyRed sql.NullBool
-	UpdatedAt   time.Time
-	Email       string
-	Password    string
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	IsChirpyRed         bool
+	UpdatedAt           time.Time
+	Email               string
+	Password            string
+	FailedLoginAttempts int32
+	LockedUntil         sql.NullTime
+	EmailVerified       bool
+	Role                string
+	SuspendedAt         sql.NullTime
+	SuspendedReason     sql.NullString
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
@@ -71,12 +165,232 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.UpdatedAt,
 		&i.Email,
 		&i.Password,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+	)
+	return i, err
+}
+
+const getUserById = `-- name: GetUserById :one
+SELECT id, created_at, updated_at, email, password, failed_login_attempts, locked_until, email_verified, role, suspended_at, suspended_reason, handle, display_name, bio, location, website, avatar_url FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
 	)
 	return i, err
 }
 
+const listUserIdsByHandles = `-- name: ListUserIdsByHandles :many
+SELECT id FROM users WHERE lower(handle) = ANY($1::text[])
+`
+
+func (q *Queries) ListUserIdsByHandles(ctx context.Context, handles []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listUserIdsByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, created_at, updated_at, email, EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = users.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red, email_verified, role, suspended_at, suspended_reason
+FROM users
+WHERE ($1::text = '' OR email ILIKE '%' || $1::text || '%')
+  AND ($2::text = '' OR role = $2::text)
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListUsersParams struct {
+	Query  string
+	Role   string
+	Limit  int32
+	Offset int32
+}
+
+type ListUsersRow struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	IsChirpyRed     bool
+	EmailVerified   bool
+	Role            string
+	SuspendedAt     sql.NullTime
+	SuspendedReason sql.NullString
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.Query,
+		arg.Role,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.IsChirpyRed,
+			&i.EmailVerified,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspendedReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUserUntil = `-- name: LockUserUntil :exec
+UPDATE users SET locked_until = $1 WHERE id = $2
+`
+
+type LockUserUntilParams struct {
+	LockedUntil sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) LockUserUntil(ctx context.Context, arg LockUserUntilParams) error {
+	_, err := q.db.ExecContext(ctx, lockUserUntil, arg.LockedUntil, arg.ID)
+	return err
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :exec
+UPDATE users SET email_verified = TRUE, updated_at = NOW() WHERE id = $1 AND email = $2
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) error {
+	_, err := q.db.ExecContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	return err
+}
+
+const recordFailedLogin = `-- name: RecordFailedLogin :one
+UPDATE users SET failed_login_attempts = failed_login_attempts + 1 WHERE id = $1 RETURNING failed_login_attempts
+`
+
+func (q *Queries) RecordFailedLogin(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFailedLogin, id)
+	var failed_login_attempts int32
+	err := row.Scan(&failed_login_attempts)
+	return failed_login_attempts, err
+}
+
+const resetFailedLogins = `-- name: ResetFailedLogins :exec
+UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1
+`
+
+func (q *Queries) ResetFailedLogins(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetFailedLogins, id)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :execresult
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, setUserRole, arg.Role, arg.ID)
+}
+
+const suspendUser = `-- name: SuspendUser :execresult
+UPDATE users SET suspended_at = NOW(), suspended_reason = $1, updated_at = NOW() WHERE id = $2
+`
+
+type SuspendUserParams struct {
+	SuspendedReason sql.NullString
+	ID              uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, suspendUser, arg.SuspendedReason, arg.ID)
+}
+
+const unlockUser = `-- name: UnlockUser :execresult
+UPDATE users SET failed_login_attempts = 0, locked_until = NULL, updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) UnlockUser(ctx context.Context, id uuid.UUID) (sql.Result, error) {
+	return q.db.ExecContext(ctx, unlockUser, id)
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :execresult
+UPDATE users SET suspended_at = NULL, suspended_reason = NULL, updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (sql.Result, error) {
+	return q.db.ExecContext(ctx, unsuspendUser, id)
+}
+
 const updateUserById = `-- name: UpdateUserById :one
-UPDATE users SET email = $1, password = $2 WHERE id = $3 RETURNING id, created_at, updated_at, email, is_chirpy_red
+UPDATE users SET email = $1, password = $2, email_verified = email_verified AND email = $1, updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = users.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red
 `
 
 type UpdateUserByIdParams struct {
@@ -90,9 +404,10 @@ type UpdateUserByIdRow struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	Email       string
-	IsChirpyRed sql.NullBool
+	IsChirpyRed bool
 }
 
+// Changing the address drops its verification.
 func (q *Queries) UpdateUserById(ctx context.Context, arg UpdateUserByIdParams) (UpdateUserByIdRow, error) {
 	row := q.db.QueryRowContext(ctx, updateUserById, arg.Email, arg.Password, arg.ID)
 	var i UpdateUserByIdRow
@@ -106,11 +421,107 @@ func (q *Queries) UpdateUserById(ctx context.Context, arg UpdateUserByIdParams)
 	return i, err
 }
 
-const upgradeToRed = `-- name: UpgradeToRed :execresult
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users SET email = $1, email_verified = FALSE, updated_at = NOW() WHERE id = $2
+RETURNING id, created_at, updated_at, email, password, failed_login_attempts, locked_until, email_verified, role, suspended_at, suspended_reason, handle, display_name, bio, location, website, avatar_url
+`
+
+type UpdateUserEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2
+`
 
-UPDATE users SET is_chirpy_red = TRUE WHERE id = $1
+type UpdateUserPasswordParams struct {
+	Password string
+	ID       uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.Password, arg.ID)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = COALESCE($1, handle),
+    display_name = COALESCE($2, display_name),
+    bio = COALESCE($3, bio),
+    location = COALESCE($4, location),
+    website = COALESCE($5, website),
+    avatar_url = COALESCE($6, avatar_url),
+    updated_at = NOW()
+WHERE id = $7
+RETURNING id, created_at, updated_at, email, password, failed_login_attempts, locked_until, email_verified, role, suspended_at, suspended_reason, handle, display_name, bio, location, website, avatar_url
 `
 
-func (q *Queries) UpgradeToRed(ctx context.Context, id uuid.UUID) (sql.Result, error) {
-	return q.db.ExecContext(ctx, upgradeToRed, id)
+type UpdateUserProfileParams struct {
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	Location    sql.NullString
+	Website     sql.NullString
+	AvatarUrl   sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.AvatarUrl,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+	)
+	return i, err
 }
@@ -0,0 +1,90 @@
+package entitlements
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// MaxChirpLength is the longest chirp any tier may allow.
+const MaxChirpLength = 4000
+
+// MaxMediaPerChirp is the most attachments any tier may allow on a chirp.
+const MaxMediaPerChirp = 10
+
+// Tier is what a class of users is allowed to do.
+type Tier struct {
+	MaxChirpLength   int `json:"max_chirp_length"`
+	MaxMediaPerChirp int `json:"max_media_per_chirp"`
+	// ChirpsPerHour caps how many chirps can be created in an hour. Zero
+	// means no limit.
+	ChirpsPerHour int  `json:"chirps_per_hour"`
+	CanEditChirps bool `json:"can_edit_chirps"`
+	// Badge is shown next to the chirps of users in the tier.
+	Badge string `json:"badge"`
+}
+
+// Tiers holds the entitlements of regular users and of Chirpy Red members.
+type Tiers struct {
+	Free Tier `json:"free"`
+	Red  Tier `json:"chirpy_red"`
+}
+
+var Default = Tiers{
+	Free: Tier{MaxChirpLength: 140, MaxMediaPerChirp: 4, ChirpsPerHour: 30},
+	Red:  Tier{MaxChirpLength: 1000, MaxMediaPerChirp: 10, ChirpsPerHour: 120, CanEditChirps: true, Badge: "chirpy_red"},
+}
+
+// For returns the tier of a user depending on whether they have Chirpy Red.
+func (t Tiers) For(red bool) Tier {
+	if red {
+		return t.Red
+	}
+	return t.Free
+}
+
+func (t Tier) Validate() error {
+	if t.MaxChirpLength < 1 || t.MaxChirpLength > MaxChirpLength {
+		return fmt.Errorf("max_chirp_length must be between 1 and %d", MaxChirpLength)
+	}
+	if t.MaxMediaPerChirp < 0 || t.MaxMediaPerChirp > MaxMediaPerChirp {
+		return fmt.Errorf("max_media_per_chirp must be between 0 and %d", MaxMediaPerChirp)
+	}
+	if t.ChirpsPerHour < 0 {
+		return errors.New("chirps_per_hour can't be negative")
+	}
+	return nil
+}
+
+// Parse reads tiers from JSON. Fields left out keep their default value, so
+// a file only has to list what it changes.
+func Parse(data []byte) (Tiers, error) {
+	tiers := Default
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&tiers); err != nil {
+		return Tiers{}, fmt.Errorf("error decoding entitlements: %w", err)
+	}
+	if err := tiers.Free.Validate(); err != nil {
+		return Tiers{}, fmt.Errorf("free: %w", err)
+	}
+	if err := tiers.Red.Validate(); err != nil {
+		return Tiers{}, fmt.Errorf("chirpy_red: %w", err)
+	}
+	return tiers, nil
+}
+
+// Load reads tiers from the JSON file at path, or returns Default when path
+// is empty.
+func Load(path string) (Tiers, error) {
+	if path == "" {
+		return Default, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Tiers{}, err
+	}
+	return Parse(data)
+}
@@ -0,0 +1,75 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Tiers
+		wantErr bool
+	}{
+		{"empty object keeps defaults", `{}`, Default, false},
+		{
+			"partial override",
+			`{"chirpy_red": {"max_chirp_length": 500}}`,
+			Tiers{Free: Default.Free, Red: Tier{MaxChirpLength: 500, MaxMediaPerChirp: 10, ChirpsPerHour: 120, CanEditChirps: true, Badge: "chirpy_red"}},
+			false,
+		},
+		{"unknown field", `{"free": {"max_length": 200}}`, Tiers{}, true},
+		{"unknown tier", `{"gold": {}}`, Tiers{}, true},
+		{"zero length", `{"free": {"max_chirp_length": 0}}`, Tiers{}, true},
+		{"length above cap", `{"chirpy_red": {"max_chirp_length": 4001}}`, Tiers{}, true},
+		{"too many media", `{"chirpy_red": {"max_media_per_chirp": 11}}`, Tiers{}, true},
+		{"negative rate", `{"free": {"chirps_per_hour": -1}}`, Tiers{}, true},
+		{"invalid json", `{`, Tiers{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	if got := Default.For(true); got != Default.Red {
+		t.Errorf("For(true) = %+v, want the red tier", got)
+	}
+	if got := Default.For(false); got != Default.Free {
+		t.Errorf("For(false) = %+v, want the free tier", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	got, err := Load("")
+	if err != nil || got != Default {
+		t.Errorf("Load(\"\") = %+v, %v, want defaults", got, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "entitlements.json")
+	if err := os.WriteFile(path, []byte(`{"free": {"max_chirp_length": 280}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Free.MaxChirpLength != 280 {
+		t.Errorf("Load() free max_chirp_length = %d, want 280", got.Free.MaxChirpLength)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
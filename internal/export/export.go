@@ -0,0 +1,54 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// File is one JSON document in an export archive.
+type File struct {
+	Name string
+	Data any
+}
+
+const readme = `This archive holds the data Chirpy stores about your account, generated %s.
+
+Each file is a JSON document:
+%s`
+
+// WriteZip writes files as indented JSON documents into a ZIP archive,
+// together with a README listing them.
+func WriteZip(w io.Writer, generatedAt time.Time, files []File) error {
+	archive := zip.NewWriter(w)
+
+	index := ""
+	for _, file := range files {
+		index += fmt.Sprintf("  - %s\n", file.Name)
+	}
+	header := &zip.FileHeader{Name: "README.txt", Method: zip.Deflate, Modified: generatedAt}
+	readmeWriter, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(readmeWriter, readme, generatedAt.UTC().Format(time.RFC3339), index); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: generatedAt}
+		fileWriter, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(fileWriter)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.Data); err != nil {
+			return fmt.Errorf("error encoding %s: %w", file.Name, err)
+		}
+	}
+
+	return archive.Close()
+}
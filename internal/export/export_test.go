@@ -0,0 +1,65 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriteZip(t *testing.T) {
+	files := []File{
+		{Name: "profile.json", Data: map[string]string{"email": "walt@example.com"}},
+		{Name: "chirps.json", Data: []string{"first", "second"}},
+		{Name: "follows.json", Data: []string{}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteZip(buf, time.Now(), files); err != nil {
+		t.Fatalf("WriteZip failed: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading archive failed: %v", err)
+	}
+
+	tests := []struct {
+		Name     string
+		Expected any
+	}{
+		{Name: "profile.json", Expected: map[string]any{"email": "walt@example.com"}},
+		{Name: "chirps.json", Expected: []any{"first", "second"}},
+		{Name: "follows.json", Expected: []any{}},
+	}
+
+	if len(archive.File) != len(tests)+1 || archive.File[0].Name != "README.txt" {
+		t.Fatalf("archive has unexpected files: %d", len(archive.File))
+	}
+	for i, tt := range tests {
+		file := archive.File[i+1]
+		if file.Name != tt.Name {
+			t.Errorf("file %d is %s, expected %s", i+1, file.Name, tt.Name)
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s failed: %v", file.Name, err)
+		}
+		raw, _ := io.ReadAll(reader)
+		reader.Close()
+
+		var got any
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Errorf("%s is not valid JSON: %v", file.Name, err)
+			continue
+		}
+		gotJSON, _ := json.Marshal(got)
+		expectedJSON, _ := json.Marshal(tt.Expected)
+		if !bytes.Equal(gotJSON, expectedJSON) {
+			t.Errorf("%s contains %s, expected %s", file.Name, gotJSON, expectedJSON)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDead    = "dead"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	// BaseBackoff is the wait after the first failed attempt. It doubles with
+	// every further failure, up to MaxBackoff.
+	BaseBackoff = 10 * time.Second
+	MaxBackoff  = time.Hour
+)
+
+// Job is a unit of work. Jobs that succeed are removed; jobs that fail
+// MaxAttempts times stay behind as dead.
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     []byte
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	// UniqueKey, when set, stops a second job with the same key from being
+	// enqueued while the first is pending or running.
+	UniqueKey string
+	LastError string
+}
+
+// Store keeps jobs. Claim must hand each due job to a single caller, and
+// give it back out once lockedUntil passes in case its worker died.
+type Store interface {
+	Insert(ctx context.Context, job Job) (bool, error)
+	Claim(ctx context.Context, kinds []string, limit int, lockedUntil time.Time) ([]Job, error)
+	Complete(ctx context.Context, id uuid.UUID) error
+	Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error
+	Kill(ctx context.Context, id uuid.UUID, lastError string) error
+}
+
+// Backoff returns how long to wait before retrying after attempts failed
+// attempts.
+func Backoff(attempts int) time.Duration {
+	delay := BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return delay
+}
+
+type Option func(*Job)
+
+// RunAt delays the job until t.
+func RunAt(t time.Time) Option {
+	return func(job *Job) { job.RunAt = t.UTC() }
+}
+
+// Unique sets the job's unique key.
+func Unique(key string) Option {
+	return func(job *Job) { job.UniqueKey = key }
+}
+
+func MaxAttempts(n int) Option {
+	return func(job *Job) { job.MaxAttempts = n }
+}
+
+// Client enqueues jobs.
+type Client struct {
+	store Store
+}
+
+func NewClient(store Store) *Client {
+	return &Client{store: store}
+}
+
+// Enqueue adds a job of kind with payload encoded as JSON. It reports false
+// when a job with the same unique key is already waiting.
+func (c *Client) Enqueue(ctx context.Context, kind string, payload any, opts ...Option) (bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("error encoding %s job: %w", kind, err)
+	}
+	job := Job{
+		ID:          uuid.New(),
+		Kind:        kind,
+		Payload:     data,
+		Status:      StatusPending,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       time.Now().UTC(),
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+	return c.store.Insert(ctx, job)
+}
+
+type handlerFunc func(ctx context.Context, job Job) error
+
+// Runner runs jobs with a pool of workers.
+type Runner struct {
+	store    Store
+	workers  int
+	handlers map[string]handlerFunc
+	// PollInterval is how long an idle worker waits before looking for work
+	// again.
+	PollInterval time.Duration
+	// Timeout bounds a single run of a job. Jobs are leased for a minute
+	// longer, so a job is only handed out again once its worker is gone.
+	Timeout time.Duration
+}
+
+func NewRunner(store Store, workers int) *Runner {
+	return &Runner{
+		store:        store,
+		workers:      max(workers, 1),
+		handlers:     map[string]handlerFunc{},
+		PollInterval: time.Second,
+		Timeout:      5 * time.Minute,
+	}
+}
+
+// Handle registers fn for jobs of kind, decoding their payload into T.
+// Handlers must be registered before Run.
+func Handle[T any](r *Runner, kind string, fn func(ctx context.Context, payload T) error) {
+	r.handlers[kind] = func(ctx context.Context, job Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return fmt.Errorf("error decoding %s job: %w", kind, err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// Run works on jobs until ctx is cancelled. It then stops taking new jobs
+// and returns once the ones in progress have finished.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context) {
+	// Jobs that already started are allowed to finish after ctx is
+	// cancelled; only their own timeout cuts them short.
+	jobCtx := context.WithoutCancel(ctx)
+	for ctx.Err() == nil {
+		ran, err := r.RunOnce(jobCtx)
+		if err != nil {
+			log.Printf("Error running jobs: %v", err)
+		}
+		if ran {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunOnce claims and runs a single due job. It reports whether there was
+// one.
+func (r *Runner) RunOnce(ctx context.Context) (bool, error) {
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	if len(kinds) == 0 {
+		return false, nil
+	}
+
+	claimed, err := r.store.Claim(ctx, kinds, 1, time.Now().UTC().Add(r.Timeout+time.Minute))
+	if err != nil {
+		return false, err
+	}
+	if len(claimed) == 0 {
+		return false, nil
+	}
+	job := claimed[0]
+
+	err = r.run(ctx, job)
+	if err == nil {
+		return true, r.store.Complete(ctx, job.ID)
+	}
+	log.Printf("Job %s (%s) failed on attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+	if job.Attempts >= job.MaxAttempts {
+		return true, r.store.Kill(ctx, job.ID, err.Error())
+	}
+	return true, r.store.Retry(ctx, job.ID, time.Now().UTC().Add(Backoff(job.Attempts)), err.Error())
+}
+
+func (r *Runner) run(ctx context.Context, job Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	handler, ok := r.handlers[job.Kind]
+	if !ok {
+		return errors.New("no handler registered")
+	}
+	return handler(ctx, job)
+}
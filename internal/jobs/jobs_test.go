@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, MaxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRunOnceDecodesPayload(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	runner := NewRunner(store, 1)
+
+	var got string
+	Handle(runner, "greet", func(ctx context.Context, payload greeting) error {
+		got = payload.Name
+		return nil
+	})
+
+	if _, err := NewClient(store).Enqueue(ctx, "greet", greeting{Name: "chirpy"}); err != nil {
+		t.Fatal(err)
+	}
+	ran, err := runner.RunOnce(ctx)
+	if !ran || err != nil {
+		t.Fatalf("RunOnce() = %v, %v", ran, err)
+	}
+	if got != "chirpy" {
+		t.Errorf("handler got %q, want %q", got, "chirpy")
+	}
+	if jobs := store.Jobs(); len(jobs) != 0 {
+		t.Errorf("finished job was kept: %+v", jobs)
+	}
+
+	if ran, _ := runner.RunOnce(ctx); ran {
+		t.Error("RunOnce() ran a job from an empty queue")
+	}
+}
+
+func TestRetriesThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	runner := NewRunner(store, 1)
+	Handle(runner, "fail", func(ctx context.Context, payload struct{}) error {
+		return errors.New("boom")
+	})
+
+	if _, err := NewClient(store).Enqueue(ctx, "fail", struct{}{}, MaxAttempts(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	if _, err := runner.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job := store.Jobs()[0]
+	if job.Status != StatusPending || job.Attempts != 1 || job.LastError != "boom" {
+		t.Fatalf("after first failure job = %+v", job)
+	}
+	if job.RunAt.Before(before.Add(BaseBackoff)) {
+		t.Errorf("retry scheduled at %v, want at least %v later", job.RunAt, BaseBackoff)
+	}
+
+	// The retry isn't due yet.
+	if ran, _ := runner.RunOnce(ctx); ran {
+		t.Fatal("RunOnce() ran a job before its retry time")
+	}
+
+	store.jobs[job.ID].RunAt = time.Now().Add(-time.Second)
+	if _, err := runner.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job = store.Jobs()[0]
+	if job.Status != StatusDead || job.Attempts != 2 {
+		t.Errorf("after last attempt job = %+v, want dead", job)
+	}
+}
+
+func TestPanicIsAFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	runner := NewRunner(store, 1)
+	Handle(runner, "panic", func(ctx context.Context, payload struct{}) error {
+		panic("oops")
+	})
+
+	NewClient(store).Enqueue(ctx, "panic", struct{}{}, MaxAttempts(1))
+	if _, err := runner.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if job := store.Jobs()[0]; job.Status != StatusDead || job.LastError != "panic: oops" {
+		t.Errorf("job = %+v, want dead with the panic recorded", job)
+	}
+}
+
+func TestUniqueAndScheduled(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	client := NewClient(store)
+
+	later := time.Now().Add(time.Hour)
+	added, err := client.Enqueue(ctx, "nightly", struct{}{}, Unique("nightly"), RunAt(later))
+	if !added || err != nil {
+		t.Fatalf("Enqueue() = %v, %v", added, err)
+	}
+	added, err = client.Enqueue(ctx, "nightly", struct{}{}, Unique("nightly"))
+	if added || err != nil {
+		t.Errorf("second Enqueue() with the same key = %v, %v, want false", added, err)
+	}
+
+	runner := NewRunner(store, 1)
+	Handle(runner, "nightly", func(ctx context.Context, payload struct{}) error { return nil })
+	if ran, _ := runner.RunOnce(ctx); ran {
+		t.Error("RunOnce() ran a job scheduled for later")
+	}
+}
+
+func TestUnregisteredKindsAreLeftAlone(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	NewClient(store).Enqueue(ctx, "other", struct{}{})
+
+	runner := NewRunner(store, 1)
+	Handle(runner, "mine", func(ctx context.Context, payload struct{}) error { return nil })
+	if ran, _ := runner.RunOnce(ctx); ran {
+		t.Error("RunOnce() claimed a job of a kind it can't handle")
+	}
+}
+
+func TestRunDrainsOnShutdown(t *testing.T) {
+	store := NewMemoryStore()
+	runner := NewRunner(store, 3)
+	runner.PollInterval = 10 * time.Millisecond
+
+	started := make(chan struct{})
+	var finished atomic.Int32
+	Handle(runner, "slow", func(ctx context.Context, payload struct{}) error {
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Error("job context was cancelled by shutdown")
+		}
+		finished.Add(1)
+		return nil
+	})
+	client := NewClient(store)
+	for i := 0; i < 3; i++ {
+		client.Enqueue(context.Background(), "slow", struct{}{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() didn't return after shutdown")
+	}
+	if finished.Load() != 3 {
+		t.Errorf("%d jobs finished before Run returned, want 3", finished.Load())
+	}
+	if jobs := store.Jobs(); len(jobs) != 0 {
+		t.Errorf("jobs left after draining: %+v", jobs)
+	}
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps jobs in memory. It is meant for tests.
+type MemoryStore struct {
+	mu          sync.Mutex
+	jobs        map[uuid.UUID]*Job
+	lockedUntil map[uuid.UUID]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: map[uuid.UUID]*Job{}, lockedUntil: map[uuid.UUID]time.Time{}}
+}
+
+func (s *MemoryStore) Insert(ctx context.Context, job Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.UniqueKey != "" {
+		for _, existing := range s.jobs {
+			if existing.UniqueKey == job.UniqueKey && existing.Status != StatusDead {
+				return false, nil
+			}
+		}
+	}
+	job.Payload = slices.Clone(job.Payload)
+	s.jobs[job.ID] = &job
+	return true, nil
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, kinds []string, limit int, lockedUntil time.Time) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var due []*Job
+	for _, job := range s.jobs {
+		if !slices.Contains(kinds, job.Kind) {
+			continue
+		}
+		pending := job.Status == StatusPending && !job.RunAt.After(now)
+		abandoned := job.Status == StatusRunning && s.lockedUntil[job.ID].Before(now)
+		if pending || abandoned {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].RunAt.Before(due[j].RunAt) })
+
+	claimed := []Job{}
+	for _, job := range due[:min(limit, len(due))] {
+		job.Status = StatusRunning
+		job.Attempts++
+		s.lockedUntil[job.ID] = lockedUntil
+		claimed = append(claimed, *job)
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	delete(s.lockedUntil, id)
+	return nil
+}
+
+func (s *MemoryStore) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		job.Status = StatusPending
+		job.RunAt = runAt
+		job.LastError = lastError
+		delete(s.lockedUntil, id)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Kill(ctx context.Context, id uuid.UUID, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		job.Status = StatusDead
+		job.LastError = lastError
+		delete(s.lockedUntil, id)
+	}
+	return nil
+}
+
+// Jobs returns a copy of every job still in the store.
+func (s *MemoryStore) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
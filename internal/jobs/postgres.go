@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/database"
+)
+
+// PostgresStore keeps jobs in the jobs table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Insert(ctx context.Context, job Job) (bool, error) {
+	result, err := s.db.InsertJob(ctx, database.InsertJobParams{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		Payload:     string(job.Payload),
+		MaxAttempts: int32(job.MaxAttempts),
+		RunAt:       job.RunAt,
+		UniqueKey:   sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""},
+	})
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, kinds []string, limit int, lockedUntil time.Time) ([]Job, error) {
+	rows, err := s.db.ClaimJobs(ctx, database.ClaimJobsParams{
+		LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+		Kinds:       kinds,
+		MaxJobs:     int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]Job, 0, len(rows))
+	for _, row := range rows {
+		claimed = append(claimed, Job{
+			ID:          row.ID,
+			Kind:        row.Kind,
+			Payload:     []byte(row.Payload),
+			Status:      row.Status,
+			Attempts:    int(row.Attempts),
+			MaxAttempts: int(row.MaxAttempts),
+			RunAt:       row.RunAt,
+			UniqueKey:   row.UniqueKey.String,
+			LastError:   row.LastError,
+		})
+	}
+	return claimed, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, id uuid.UUID) error {
+	return s.db.CompleteJob(ctx, id)
+}
+
+func (s *PostgresStore) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	return s.db.RetryJob(ctx, database.RetryJobParams{ID: id, RunAt: runAt, LastError: lastError})
+}
+
+func (s *PostgresStore) Kill(ctx context.Context, id uuid.UUID, lastError string) error {
+	return s.db.KillJob(ctx, database.KillJobParams{ID: id, LastError: lastError})
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrHeaderInjection is returned for messages whose sender, recipient or
+// subject contain a line break, which would let them add their own headers.
+var ErrHeaderInjection = errors.New("mail header contains a line break")
+
+func formatMessage(from string, msg Message) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrHeaderInjection
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
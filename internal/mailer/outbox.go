@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OutboxMailer writes every message to Dir as an .eml file instead of
+// delivering it, and keeps a copy in memory. Meant for development and tests.
+type OutboxMailer struct {
+	Dir  string
+	From string
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewOutboxMailer(dir, from string) *OutboxMailer {
+	return &OutboxMailer{Dir: dir, From: from}
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := formatMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Dir != "" {
+		if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+			return fmt.Errorf("error creating outbox dir: %w", err)
+		}
+		name := fmt.Sprintf("%d-%03d.eml", time.Now().UnixNano(), len(m.sent))
+		if err := os.WriteFile(filepath.Join(m.Dir, name), data, 0o644); err != nil {
+			return fmt.Errorf("error writing outbox message: %w", err)
+		}
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent returns a copy of every message sent so far.
+func (m *OutboxMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOutboxMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewOutboxMailer(dir, "chirpy@example.com")
+
+	msg := Message{To: "user@example.com", Subject: "Verify your email", Body: "line one\nline two"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0] != msg {
+		t.Fatalf("Sent() = %v, expected [%v]", sent, msg)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one file in outbox, got %d (%v)", len(entries), err)
+	}
+	data, err := os.ReadFile(dir + "/" + entries[0].Name())
+	if err != nil {
+		t.Fatalf("error reading outbox file: %v", err)
+	}
+	for _, want := range []string{"To: user@example.com\r\n", "Subject: Verify your email\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("outbox file missing %q", want)
+		}
+	}
+}
+
+func TestOutboxMailerRejectsHeaderInjection(t *testing.T) {
+	m := NewOutboxMailer(t.TempDir(), "chirpy@example.com")
+
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{name: "Recipient", msg: Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi"}},
+		{name: "Subject", msg: Message{To: "user@example.com", Subject: "Hi\nBcc: victim@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Send(context.Background(), tt.msg); !errors.Is(err, ErrHeaderInjection) {
+				t.Errorf("Send() error = %v, expected %v", err, ErrHeaderInjection)
+			}
+		})
+	}
+	if sent := m.Sent(); len(sent) != 0 {
+		t.Errorf("Sent() = %v, expected nothing", sent)
+	}
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{Host: host, Port: port, Username: username, Password: password, From: from}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := formatMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, data)
+}
@@ -0,0 +1,337 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type, upload a JPEG, PNG or GIF image")
+	ErrTooLarge        = errors.New("media is too large")
+)
+
+type Limits struct {
+	MaxBytes int64
+	// MaxPixels bounds width*height so small files can't decode into huge
+	// images.
+	MaxPixels int
+	// MaxFrames bounds the number of frames of an animated GIF. The pixels of
+	// all frames together must also fit in MaxPixels.
+	MaxFrames int
+	// ThumbnailSize is the longest side of a thumbnail.
+	ThumbnailSize int
+}
+
+var DefaultLimits = Limits{
+	MaxBytes:      5 << 20,
+	MaxPixels:     40_000_000,
+	MaxFrames:     500,
+	ThumbnailSize: 320,
+}
+
+// Image is an upload after processing. Data is re-encoded from the decoded
+// pixels, so EXIF and any other metadata in the original is dropped.
+type Image struct {
+	ContentType   string
+	Data          []byte
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// Process checks that data is an image of a supported type within limits,
+// strips its metadata and renders a thumbnail. The content type is sniffed
+// from the bytes; whatever the client claimed is ignored.
+func Process(data []byte, limits Limits) (Image, error) {
+	if int64(len(data)) > limits.MaxBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > limits.MaxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	var out bytes.Buffer
+	var first image.Image
+	switch contentType {
+	case "image/gif":
+		// Every frame is decoded, so check them all before decoding any.
+		frames, pixels, ok := gifFrames(data)
+		if !ok {
+			return Image{}, ErrUnsupportedType
+		}
+		if frames > limits.MaxFrames || pixels > limits.MaxPixels {
+			return Image{}, ErrTooLarge
+		}
+		// Keep every frame so animations survive; comment and application
+		// extensions are not written back.
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		if err := gif.EncodeAll(&out, anim); err != nil {
+			return Image{}, fmt.Errorf("error encoding gif: %w", err)
+		}
+		first = anim.Image[0]
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		// The orientation tag goes with the rest of the EXIF data, so apply
+		// it to the pixels first.
+		first = applyOrientation(img, jpegOrientation(data))
+		if err := jpeg.Encode(&out, first, &jpeg.Options{Quality: 90}); err != nil {
+			return Image{}, fmt.Errorf("error encoding jpeg: %w", err)
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		first = img
+		if err := png.Encode(&out, img); err != nil {
+			return Image{}, fmt.Errorf("error encoding png: %w", err)
+		}
+	}
+
+	bounds := first.Bounds()
+	result := Image{
+		ContentType: contentType,
+		Data:        out.Bytes(),
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}
+
+	var thumb bytes.Buffer
+	small := Thumbnail(first, limits.ThumbnailSize)
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&thumb, small, &jpeg.Options{Quality: 80})
+		result.ThumbnailType = "image/jpeg"
+	} else {
+		err = png.Encode(&thumb, small)
+		result.ThumbnailType = "image/png"
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+	result.Thumbnail = thumb.Bytes()
+	return result, nil
+}
+
+// Thumbnail scales img down so its longest side is at most size, averaging
+// the source pixels that fall into each destination pixel. Images that
+// already fit are copied unchanged.
+func Thumbnail(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW, dstH = size, max(1, srcH*size/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*size/srcH), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	if dstW == srcW && dstH == srcH {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// gifFrames walks the blocks of a GIF and returns how many frames it has and
+// their total area, without decoding them. ok is false when the stream is
+// malformed.
+func gifFrames(data []byte) (frames, pixels int, ok bool) {
+	if len(data) < 13 {
+		return 0, 0, false
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B:
+			return frames, pixels, true
+		case 0x21:
+			if pos+2 > len(data) {
+				return 0, 0, false
+			}
+			pos += 2
+		case 0x2C:
+			if pos+10 > len(data) {
+				return 0, 0, false
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			frames++
+			pixels += width * height
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			// LZW minimum code size.
+			pos++
+		default:
+			return 0, 0, false
+		}
+		// Skip the data sub-blocks up to the zero-length terminator.
+		for {
+			if pos >= len(data) {
+				return 0, 0, false
+			}
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+	// Like image/gif, accept a stream that ends without a trailer.
+	return frames, pixels, true
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, or 1 when it has
+// none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value < 1 || value > 8 {
+				return 1
+			}
+			return value
+		}
+	}
+	return 1
+}
+
+// applyOrientation turns img upright according to an EXIF orientation value.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,160 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 segment carrying orientation and a marker string
+// right after the SOI marker of a JPEG.
+func withExif(t *testing.T, data []byte, orientation uint16, marker string) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(2))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x010e, 2})
+	binary.Write(&tiff, binary.BigEndian, uint32(len(marker)))
+	binary.Write(&tiff, binary.BigEndian, uint32(tiff.Len()+8))
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString(marker)
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestProcessJPEGStripsExif(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(60, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withExif(t, buf.Bytes(), 6, "GPS 51.5N 0.1W")
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, expected 6", got)
+	}
+
+	img, err := Process(data, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if img.ContentType != "image/jpeg" || img.ThumbnailType != "image/jpeg" {
+		t.Errorf("types = %s, %s, expected image/jpeg", img.ContentType, img.ThumbnailType)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPS 51.5N")) {
+		t.Error("processed image still carries EXIF data")
+	}
+	// Orientation 6 is a quarter turn, so width and height swap.
+	if img.Width != 20 || img.Height != 60 {
+		t.Errorf("size = %dx%d, expected 20x60", img.Width, img.Height)
+	}
+}
+
+func TestProcessPNGThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(1000, 500)); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Process(buf.Bytes(), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if img.ContentType != "image/png" || img.Width != 1000 || img.Height != 500 {
+		t.Fatalf("got %s %dx%d, expected image/png 1000x500", img.ContentType, img.Width, img.Height)
+	}
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail is not a png: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+		t.Errorf("thumbnail size = %dx%d, expected 320x160", b.Dx(), b.Dy())
+	}
+}
+
+// testGIF encodes an animation of blank w by h frames.
+func testGIF(t *testing.T, frames, w, h int) []byte {
+	t.Helper()
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var out bytes.Buffer
+	if err := gif.EncodeAll(&out, anim); err != nil {
+		t.Fatalf("EncodeAll: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestGIFFrames(t *testing.T) {
+	data := testGIF(t, 3, 20, 10)
+	frames, pixels, ok := gifFrames(data)
+	if !ok || frames != 3 || pixels != 600 {
+		t.Errorf("gifFrames = %d, %d, %v, expected 3, 600, true", frames, pixels, ok)
+	}
+	if _, _, ok := gifFrames(data[:20]); ok {
+		t.Errorf("gifFrames accepted a truncated gif")
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	var small bytes.Buffer
+	png.Encode(&small, testImage(50, 50))
+	anim := testGIF(t, 4, 10, 10)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		limits Limits
+		want   error
+	}{
+		{"text", []byte("hello, this is not an image"), DefaultLimits, ErrUnsupportedType},
+		{"truncated png", small.Bytes()[:40], DefaultLimits, ErrUnsupportedType},
+		{"too many bytes", small.Bytes(), Limits{MaxBytes: 10, MaxPixels: 1 << 20, ThumbnailSize: 10}, ErrTooLarge},
+		{"too many pixels", small.Bytes(), Limits{MaxBytes: 1 << 20, MaxPixels: 100, ThumbnailSize: 10}, ErrTooLarge},
+		{"too many frames", anim, Limits{MaxBytes: 1 << 20, MaxPixels: 1 << 20, MaxFrames: 3, ThumbnailSize: 10}, ErrTooLarge},
+		{"too many pixels across frames", anim, Limits{MaxBytes: 1 << 20, MaxPixels: 399, MaxFrames: 10, ThumbnailSize: 10}, ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data, tt.limits); !errors.Is(err, tt.want) {
+				t.Errorf("Process error = %v, expected %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestThumbnailKeepsSmallImages(t *testing.T) {
+	thumb := Thumbnail(testImage(40, 30), 320)
+	if b := thumb.Bounds(); b.Dx() != 40 || b.Dy() != 30 {
+		t.Errorf("thumbnail size = %dx%d, expected 40x30", b.Dx(), b.Dy())
+	}
+}
@@ -0,0 +1,66 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Who may start a conversation with a user.
+const (
+	PolicyEveryone  = "everyone"
+	PolicyFollowing = "following"
+	PolicyNobody    = "nobody"
+)
+
+const (
+	// MaxBodyLength is the message limit. It is separate from the chirp
+	// limits: messages don't depend on the sender's tier.
+	MaxBodyLength = 2000
+	// MaxMembers caps the size of a group conversation, its creator
+	// included.
+	MaxMembers = 10
+)
+
+var (
+	ErrEmptyBody   = errors.New("message can't be empty")
+	ErrBodyTooLong = fmt.Errorf("message is too long, the limit is %d characters", MaxBodyLength)
+)
+
+func IsValidPolicy(policy string) bool {
+	return policy == PolicyEveryone || policy == PolicyFollowing || policy == PolicyNobody
+}
+
+// CanMessage reports whether a recipient with policy accepts new
+// conversations from a sender. followsSender is whether the recipient
+// follows the sender.
+func CanMessage(policy string, followsSender bool) bool {
+	switch policy {
+	case PolicyEveryone:
+		return true
+	case PolicyFollowing:
+		return followsSender
+	default:
+		return false
+	}
+}
+
+// ValidateBody checks a message body before it is cleaned like a chirp.
+func ValidateBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return ErrEmptyBody
+	}
+	if len(body) > MaxBodyLength {
+		return ErrBodyTooLong
+	}
+	return nil
+}
+
+// DirectKey identifies the one-to-one conversation between two users,
+// whichever of them starts it.
+func DirectKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + ":" + b
+}
@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanMessage(t *testing.T) {
+	tests := []struct {
+		policy        string
+		followsSender bool
+		want          bool
+	}{
+		{PolicyEveryone, false, true},
+		{PolicyEveryone, true, true},
+		{PolicyFollowing, false, false},
+		{PolicyFollowing, true, true},
+		{PolicyNobody, true, false},
+		{"unknown", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanMessage(tt.policy, tt.followsSender); got != tt.want {
+			t.Errorf("CanMessage(%q, %v) = %v, want %v", tt.policy, tt.followsSender, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidPolicy(t *testing.T) {
+	for _, policy := range []string{PolicyEveryone, PolicyFollowing, PolicyNobody} {
+		if !IsValidPolicy(policy) {
+			t.Errorf("IsValidPolicy(%q) = false", policy)
+		}
+	}
+	if IsValidPolicy("friends") {
+		t.Error(`IsValidPolicy("friends") = true`)
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{"ok", "hello", nil},
+		{"at limit", strings.Repeat("a", MaxBodyLength), nil},
+		{"empty", "", ErrEmptyBody},
+		{"blank", " \n\t", ErrEmptyBody},
+		{"too long", strings.Repeat("a", MaxBodyLength+1), ErrBodyTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateBody(tt.body); !errors.Is(err, tt.want) {
+				t.Errorf("ValidateBody() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectKey(t *testing.T) {
+	if DirectKey("a", "b") != DirectKey("b", "a") {
+		t.Error("DirectKey depends on argument order")
+	}
+	if DirectKey("a", "b") == DirectKey("a", "c") {
+		t.Error("DirectKey collides for different pairs")
+	}
+}
@@ -0,0 +1,59 @@
+package moderation
+
+const (
+	TargetChirp = "chirp"
+	TargetUser  = "user"
+)
+
+const (
+	StatusOpen      = "open"
+	StatusClaimed   = "claimed"
+	StatusResolved  = "resolved"
+	StatusDismissed = "dismissed"
+)
+
+const (
+	ResolutionHideChirp   = "hide_chirp"
+	ResolutionDeleteChirp = "delete_chirp"
+	ResolutionSuspendUser = "suspend_user"
+)
+
+var reasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"sexual":         true,
+	"misinformation": true,
+	"impersonation":  true,
+	"other":          true,
+}
+
+func IsValidReason(reason string) bool {
+	return reasons[reason]
+}
+
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusOpen, StatusClaimed, StatusResolved, StatusDismissed:
+		return true
+	}
+	return false
+}
+
+// IsValidResolution reports whether resolution can be applied to a report
+// on targetType. Chirp reports can also be resolved by suspending the author.
+func IsValidResolution(targetType, resolution string) bool {
+	switch resolution {
+	case ResolutionHideChirp, ResolutionDeleteChirp:
+		return targetType == TargetChirp
+	case ResolutionSuspendUser:
+		return targetType == TargetChirp || targetType == TargetUser
+	}
+	return false
+}
+
+// IsPending reports whether a report with status still needs a decision.
+func IsPending(status string) bool {
+	return status == StatusOpen || status == StatusClaimed
+}
@@ -0,0 +1,44 @@
+package moderation
+
+import "testing"
+
+func TestIsValidResolution(t *testing.T) {
+	tests := []struct {
+		TargetType string
+		Resolution string
+		Expected   bool
+	}{
+		{TargetType: TargetChirp, Resolution: ResolutionHideChirp, Expected: true},
+		{TargetType: TargetChirp, Resolution: ResolutionDeleteChirp, Expected: true},
+		{TargetType: TargetChirp, Resolution: ResolutionSuspendUser, Expected: true},
+		{TargetType: TargetUser, Resolution: ResolutionSuspendUser, Expected: true},
+		{TargetType: TargetUser, Resolution: ResolutionHideChirp, Expected: false},
+		{TargetType: TargetUser, Resolution: ResolutionDeleteChirp, Expected: false},
+		{TargetType: TargetChirp, Resolution: "ban", Expected: false},
+		{TargetType: "dm", Resolution: ResolutionSuspendUser, Expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidResolution(tt.TargetType, tt.Resolution); got != tt.Expected {
+			t.Errorf("IsValidResolution(%q, %q) returned %v, expected %v", tt.TargetType, tt.Resolution, got, tt.Expected)
+		}
+	}
+}
+
+func TestIsPending(t *testing.T) {
+	tests := []struct {
+		Status   string
+		Expected bool
+	}{
+		{Status: StatusOpen, Expected: true},
+		{Status: StatusClaimed, Expected: true},
+		{Status: StatusResolved, Expected: false},
+		{Status: StatusDismissed, Expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPending(tt.Status); got != tt.Expected {
+			t.Errorf("IsPending(%q) returned %v, expected %v", tt.Status, got, tt.Expected)
+		}
+	}
+}
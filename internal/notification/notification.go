@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	TypeMention    = "mention"
+	TypeReply      = "reply"
+	TypeFollow     = "follow"
+	TypeChirpyRed  = "chirpy_red"
+	TypePollClosed = "poll_closed"
+)
+
+// Types lists every notification type, in the order preferences are shown.
+var Types = []string{TypeMention, TypeReply, TypeFollow, TypeChirpyRed, TypePollClosed}
+
+// MaxMentions caps how many users a single chirp can notify.
+const MaxMentions = 10
+
+var mentionPattern = regexp.MustCompile(`@[A-Za-z0-9_]+`)
+
+func IsKnownType(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractMentions returns the handles mentioned in body, lowercased and
+// without duplicates. "@" inside a word, as in an email address, is not a
+// mention.
+func ExtractMentions(body string) []string {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, loc := range mentionPattern.FindAllStringIndex(body, -1) {
+		if loc[0] > 0 && isHandleChar(body[loc[0]-1]) {
+			continue
+		}
+		handle := strings.ToLower(body[loc[0]+1 : loc[1]])
+		if len(handle) < 3 || len(handle) > 30 || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+		if len(handles) == MaxMentions {
+			break
+		}
+	}
+	return handles
+}
+
+func isHandleChar(c byte) bool {
+	return c == '_' || c == '@' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,50 @@
+package notification
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	many := []string{}
+	for i := 0; i < MaxMentions+2; i++ {
+		many = append(many, fmt.Sprintf("@user%d", i))
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "hello world", []string{}},
+		{"single", "hi @Alice!", []string{"alice"}},
+		{"start and punctuation", "@bob, @carol_1: look", []string{"bob", "carol_1"}},
+		{"duplicates", "@Bob @bob @BOB", []string{"bob"}},
+		{"email", "mail me at dan@example.com", []string{}},
+		{"double at", "@@eve", []string{}},
+		{"too short", "@ab", []string{}},
+		{"too long", "@" + strings.Repeat("x", 31), []string{}},
+		{"capped", strings.Join(many, " "), []string{"user0", "user1", "user2", "user3", "user4", "user5", "user6", "user7", "user8", "user9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractMentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsKnownType(t *testing.T) {
+	for _, typ := range Types {
+		if !IsKnownType(typ) {
+			t.Errorf("IsKnownType(%q) = false", typ)
+		}
+	}
+	if IsKnownType("like") {
+		t.Error(`IsKnownType("like") = true`)
+	}
+}
@@ -0,0 +1,210 @@
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserFollowed = "user.followed"
+	EventUserUpgraded = "user.upgraded"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is moved to
+	// the dead-letter state.
+	MaxAttempts = 8
+	// BaseBackoff is the wait after the first failed attempt. It doubles with
+	// every further failure, up to MaxBackoff.
+	BaseBackoff = 30 * time.Second
+	MaxBackoff  = 6 * time.Hour
+	// Timeout bounds a single delivery attempt.
+	Timeout = 10 * time.Second
+)
+
+var ErrPrivateAddress = errors.New("webhook endpoints can't point to private addresses")
+
+func IsKnownEvent(event string) bool {
+	switch event {
+	case EventChirpCreated, EventChirpDeleted, EventUserFollowed, EventUserUpgraded:
+		return true
+	}
+	return false
+}
+
+// Event is the JSON envelope delivered to endpoints. ID is shared by every
+// delivery of the same event so receivers can deduplicate.
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func NewEvent(eventType string, data any) Event {
+	return Event{ID: uuid.New(), Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+}
+
+// MakeSecret returns a new random signing secret for an endpoint.
+func MakeSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// ValidateURL checks an endpoint URL. Only HTTPS is accepted unless
+// allowInsecure is set, which is meant for local development.
+func ValidateURL(raw string, allowInsecure bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute URL")
+	}
+	if u.User != nil {
+		return errors.New("url can't contain credentials")
+	}
+	if u.Scheme != "https" && !(allowInsecure && u.Scheme == "http") {
+		return errors.New("url must use https")
+	}
+	return nil
+}
+
+// Backoff returns how long to wait before retrying after attempts failed
+// attempts.
+func Backoff(attempts int) time.Duration {
+	delay := BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Sender posts signed events to endpoints.
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender returns a Sender whose connections can only reach public
+// addresses, so endpoints can't be used to probe the internal network.
+// allowPrivate lifts that restriction for local development.
+func NewSender(allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: Timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || isPrivate(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Sender{Client: &http.Client{
+		Timeout:   Timeout,
+		Transport: transport,
+		// A redirect would send the signed body somewhere the owner didn't
+		// register.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// privatePrefixes lists the special-purpose ranges from the IANA registries
+// that an endpoint must not reach: local networks, shared and benchmarking
+// space, documentation ranges, multicast, and the IPv6 translation and
+// tunnelling prefixes that can wrap an IPv4 address.
+var privatePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+func isPrivate(ip netip.Addr) bool {
+	// An IPv4-mapped address is dialled as the IPv4 address it wraps.
+	ip = ip.Unmap()
+	for _, prefix := range privatePrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliver posts body to endpointURL, signed with secret. It returns the
+// response status code, and an error unless the endpoint answered with a
+// 2xx status.
+func (s *Sender) Deliver(ctx context.Context, endpointURL, secret, eventType string, deliveryID uuid.UUID, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("X-Chirpy-Event", eventType)
+	req.Header.Set("X-Chirpy-Delivery", deliveryID.String())
+	req.Header.Set("X-Chirpy-Signature", auth.SignWebhook(secret, time.Now(), body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
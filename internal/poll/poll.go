@@ -0,0 +1,61 @@
+package poll
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MinOptions      = 2
+	MaxOptions      = 4
+	MaxOptionLength = 25
+	MinDuration     = 5 * time.Minute
+	MaxDuration     = 7 * 24 * time.Hour
+)
+
+var ErrDuplicateOption = errors.New("poll options must be different from each other")
+
+// Validate checks the options of a new poll and that it closes between
+// MinDuration and MaxDuration after opensAt, which is when its chirp is
+// published.
+func Validate(options []string, closesAt, opensAt time.Time) error {
+	if len(options) < MinOptions || len(options) > MaxOptions {
+		return fmt.Errorf("a poll must have %d to %d options", MinOptions, MaxOptions)
+	}
+	seen := map[string]bool{}
+	for _, option := range options {
+		label := strings.TrimSpace(option)
+		if label == "" {
+			return errors.New("poll options can't be empty")
+		}
+		if utf8.RuneCountInString(label) > MaxOptionLength {
+			return fmt.Errorf("poll options must be at most %d characters", MaxOptionLength)
+		}
+		key := strings.ToLower(label)
+		if seen[key] {
+			return ErrDuplicateOption
+		}
+		seen[key] = true
+	}
+
+	return ValidateDuration(closesAt, opensAt)
+}
+
+// ValidateDuration checks that a poll opening at opensAt closes between
+// MinDuration and MaxDuration later.
+func ValidateDuration(closesAt, opensAt time.Time) error {
+	duration := closesAt.Sub(opensAt)
+	if duration < MinDuration || duration > MaxDuration {
+		return fmt.Errorf("a poll must stay open between %s and %s", MinDuration, MaxDuration)
+	}
+	return nil
+}
+
+// IsClosed reports whether a poll no longer takes votes. The background job
+// sets closedAt, but a poll is closed as soon as closesAt passes.
+func IsClosed(closesAt time.Time, closed bool, now time.Time) bool {
+	return closed || !now.Before(closesAt)
+}
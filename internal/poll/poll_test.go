@@ -0,0 +1,67 @@
+package poll
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		options  []string
+		closesAt time.Time
+		wantErr  bool
+	}{
+		{"two options", []string{"Yes", "No"}, day, false},
+		{"four options", []string{"a", "b", "c", "d"}, day, false},
+		{"one option", []string{"Yes"}, day, true},
+		{"five options", []string{"a", "b", "c", "d", "e"}, day, true},
+		{"empty option", []string{"Yes", "  "}, day, true},
+		{"long option", []string{"Yes", strings.Repeat("x", MaxOptionLength+1)}, day, true},
+		{"duplicate option", []string{"Yes", "yes "}, day, true},
+		{"too short", []string{"Yes", "No"}, now.Add(time.Minute), true},
+		{"too long", []string{"Yes", "No"}, now.Add(MaxDuration + time.Second), true},
+		{"already closed", []string{"Yes", "No"}, now.Add(-time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.options, tt.closesAt, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := Validate([]string{"Yes", "YES"}, day, now); !errors.Is(err, ErrDuplicateOption) {
+		t.Errorf("Validate() error = %v, expected ErrDuplicateOption", err)
+	}
+}
+
+func TestIsClosed(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		closesAt time.Time
+		closed   bool
+		want     bool
+	}{
+		{"open", now.Add(time.Hour), false, false},
+		{"expired", now.Add(-time.Hour), false, true},
+		{"at expiry", now, false, true},
+		{"closed by job", now.Add(time.Hour), true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsClosed(tt.closesAt, tt.closed, now); got != tt.want {
+				t.Errorf("IsClosed() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
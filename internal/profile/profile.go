@@ -0,0 +1,51 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+)
+
+const (
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 160
+	MaxLocationLength    = 30
+	MaxURLLength         = 200
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+var ErrInvalidHandle = errors.New("handle must be 3 to 30 letters, digits or underscores")
+
+func ValidateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return ErrInvalidHandle
+	}
+	return nil
+}
+
+// ValidateText checks that value is at most max characters long.
+func ValidateText(field, value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%s must be at most %d characters", field, max)
+	}
+	return nil
+}
+
+// ValidateURL accepts an empty value, which clears the field, or an absolute
+// http or https URL.
+func ValidateURL(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > MaxURLLength {
+		return fmt.Errorf("%s must be at most %d characters", field, MaxURLLength)
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", field)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		Handle  string
+		IsValid bool
+	}{
+		{Handle: "chirpy_fan", IsValid: true},
+		{Handle: "abc", IsValid: true},
+		{Handle: "ab", IsValid: false},
+		{Handle: strings.Repeat("a", 31), IsValid: false},
+		{Handle: "has space", IsValid: false},
+		{Handle: "dash-ed", IsValid: false},
+		{Handle: "", IsValid: false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateHandle(tt.Handle)
+		if (err == nil) != tt.IsValid {
+			t.Errorf("ValidateHandle(%q) returned %v, expected valid %v", tt.Handle, err, tt.IsValid)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		URL     string
+		IsValid bool
+	}{
+		{URL: "", IsValid: true},
+		{URL: "https://example.com/me", IsValid: true},
+		{URL: "http://example.com", IsValid: true},
+		{URL: "javascript:alert(1)", IsValid: false},
+		{URL: "ftp://example.com", IsValid: false},
+		{URL: "example.com", IsValid: false},
+		{URL: "https://" + strings.Repeat("a", MaxURLLength), IsValid: false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateURL("website", tt.URL)
+		if (err == nil) != tt.IsValid {
+			t.Errorf("ValidateURL(%q) returned %v, expected valid %v", tt.URL, err, tt.IsValid)
+		}
+	}
+}
+
+func TestValidateText(t *testing.T) {
+	if err := ValidateText("bio", strings.Repeat("é", MaxBioLength), MaxBioLength); err != nil {
+		t.Errorf("ValidateText counted bytes instead of characters: %v", err)
+	}
+	if err := ValidateText("bio", strings.Repeat("a", MaxBioLength+1), MaxBioLength); err == nil {
+		t.Errorf("ValidateText accepted a value over the limit")
+	}
+}
@@ -0,0 +1,55 @@
+package pubsub
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// pingInterval is how often an idle listener checks its connection, so a
+// silently dropped connection is noticed and re-established.
+const pingInterval = time.Minute
+
+// Listen passes the payload of every NOTIFY on channel to handle until ctx
+// is done. Notifications sent while the connection is down are lost, so
+// after every reconnect handle is called with an empty payload to let the
+// caller catch up.
+func Listen(ctx context.Context, connStr, channel string, handle func(payload string)) error {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Error listening on %s: %v", channel, err)
+		}
+	})
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	if err := listener.Listen(channel); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return nil
+			}
+			if n == nil {
+				handle("")
+				continue
+			}
+			handle(n.Extra)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pubsub
+
+import "sync"
+
+// BufferSize is how many messages a subscriber can fall behind before new
+// ones are dropped for it.
+const BufferSize = 16
+
+// Hub fans out messages published under a key to every subscriber of that
+// key within this process.
+type Hub[K comparable, T any] struct {
+	mu     sync.Mutex
+	subs   map[K]map[chan T]struct{}
+	closed bool
+}
+
+func NewHub[K comparable, T any]() *Hub[K, T] {
+	return &Hub[K, T]{subs: map[K]map[chan T]struct{}{}}
+}
+
+// Subscribe returns a channel receiving the messages published under key,
+// and a function that ends the subscription. The channel is closed when the
+// subscription ends or the hub is closed.
+func (h *Hub[K, T]) Subscribe(key K) (<-chan T, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan T, BufferSize)
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subs[key] == nil {
+		h.subs[key] = map[chan T]struct{}{}
+	}
+	h.subs[key][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[key][ch]; !ok {
+			return
+		}
+		delete(h.subs[key], ch)
+		if len(h.subs[key]) == 0 {
+			delete(h.subs, key)
+		}
+		close(ch)
+	}
+}
+
+// Publish sends msg to the subscribers of key without blocking. A subscriber
+// whose buffer is full misses msg.
+func (h *Hub[K, T]) Publish(key K, msg T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[key] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription, so long-lived streams return on shutdown.
+func (h *Hub[K, T]) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for key, chans := range h.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(h.subs, key)
+	}
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import "testing"
+
+func TestPublishReachesSubscribersOfKey(t *testing.T) {
+	hub := NewHub[string, int]()
+	a1, cancelA1 := hub.Subscribe("a")
+	defer cancelA1()
+	a2, cancelA2 := hub.Subscribe("a")
+	defer cancelA2()
+	b, cancelB := hub.Subscribe("b")
+	defer cancelB()
+
+	hub.Publish("a", 1)
+
+	for _, ch := range []<-chan int{a1, a2} {
+		if got := <-ch; got != 1 {
+			t.Errorf("received %d, want 1", got)
+		}
+	}
+	select {
+	case got := <-b:
+		t.Errorf("subscriber of another key received %d", got)
+	default:
+	}
+}
+
+func TestCancelClosesChannel(t *testing.T) {
+	hub := NewHub[string, int]()
+	ch, cancel := hub.Subscribe("a")
+	cancel()
+	cancel()
+
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after cancel")
+	}
+	hub.Publish("a", 1)
+	if len(hub.subs) != 0 {
+		t.Errorf("subscriptions left behind: %v", hub.subs)
+	}
+}
+
+func TestSlowSubscriberMissesMessages(t *testing.T) {
+	hub := NewHub[string, int]()
+	ch, cancel := hub.Subscribe("a")
+	defer cancel()
+
+	for i := 0; i < BufferSize+5; i++ {
+		hub.Publish("a", i)
+	}
+	if len(ch) != BufferSize {
+		t.Errorf("buffered %d messages, want %d", len(ch), BufferSize)
+	}
+}
+
+func TestClose(t *testing.T) {
+	hub := NewHub[string, int]()
+	ch, cancel := hub.Subscribe("a")
+	hub.Close()
+	cancel()
+
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after Close")
+	}
+	late, _ := hub.Subscribe("a")
+	if _, ok := <-late; ok {
+		t.Error("subscription after Close is open")
+	}
+}
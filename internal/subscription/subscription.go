@@ -0,0 +1,91 @@
+package subscription
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	StatusActive   = "active"
+	StatusPastDue  = "past_due"
+	StatusCanceled = "canceled"
+	StatusRefunded = "refunded"
+	StatusExpired  = "expired"
+	// StatusNone is the state of a user who never subscribed.
+	StatusNone = ""
+)
+
+const (
+	EventUpgraded      = "user.upgraded"
+	EventRenewed       = "user.renewed"
+	EventDowngraded    = "user.downgraded"
+	EventRefunded      = "user.refunded"
+	EventPaymentFailed = "user.payment_failed"
+)
+
+// DefaultPeriod is the billing period used when an event carries no period
+// end.
+const DefaultPeriod = 30 * 24 * time.Hour
+
+var ErrUnknownEvent = errors.New("unknown subscription event")
+
+// State is a user's subscription. A zero PeriodEnd means the membership has
+// no end date, which is how memberships from before periods were tracked
+// are carried over.
+type State struct {
+	Status    string
+	PeriodEnd time.Time
+}
+
+func IsKnownEvent(event string) bool {
+	switch event {
+	case EventUpgraded, EventRenewed, EventDowngraded, EventRefunded, EventPaymentFailed:
+		return true
+	}
+	return false
+}
+
+// IsActive reports whether a subscription grants Chirpy Red at now. A failed
+// payment keeps access until the period the user already paid for ends.
+func IsActive(state State, now time.Time) bool {
+	if state.Status != StatusActive && state.Status != StatusPastDue {
+		return false
+	}
+	return state.PeriodEnd.IsZero() || now.Before(state.PeriodEnd)
+}
+
+// Apply returns the state after event. periodEnd is the end of the paid
+// period reported by the payment provider, or zero when it sent none.
+func Apply(current State, event string, periodEnd, now time.Time) (State, error) {
+	switch event {
+	case EventUpgraded, EventRenewed:
+		if periodEnd.IsZero() {
+			// A renewal extends the current period rather than restarting it.
+			start := now
+			if event == EventRenewed && IsActive(current, now) && !current.PeriodEnd.IsZero() {
+				start = current.PeriodEnd
+			}
+			periodEnd = start.Add(DefaultPeriod)
+		}
+		return State{Status: StatusActive, PeriodEnd: periodEnd}, nil
+	case EventPaymentFailed:
+		if !IsActive(current, now) {
+			return current, nil
+		}
+		return State{Status: StatusPastDue, PeriodEnd: current.PeriodEnd}, nil
+	case EventDowngraded:
+		return State{Status: StatusCanceled, PeriodEnd: now}, nil
+	case EventRefunded:
+		return State{Status: StatusRefunded, PeriodEnd: now}, nil
+	}
+	return current, ErrUnknownEvent
+}
+
+// Expire returns the state once its period has run out. States that still
+// grant access, or that already ended for another reason, are unchanged.
+func Expire(state State, now time.Time) State {
+	if (state.Status == StatusActive || state.Status == StatusPastDue) && !IsActive(state, now) {
+		return State{Status: StatusExpired, PeriodEnd: state.PeriodEnd}
+	}
+	return state
+}
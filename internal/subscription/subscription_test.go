@@ -0,0 +1,76 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(10 * 24 * time.Hour)
+	reported := now.Add(365 * 24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		current   State
+		event     string
+		periodEnd time.Time
+		want      State
+		wantErr   error
+	}{
+		{"upgrade new user", State{}, EventUpgraded, time.Time{}, State{StatusActive, now.Add(DefaultPeriod)}, nil},
+		{"upgrade with reported period", State{}, EventUpgraded, reported, State{StatusActive, reported}, nil},
+		{"renew extends period", State{StatusActive, periodEnd}, EventRenewed, time.Time{}, State{StatusActive, periodEnd.Add(DefaultPeriod)}, nil},
+		{"renew lapsed restarts period", State{StatusExpired, now.Add(-time.Hour)}, EventRenewed, time.Time{}, State{StatusActive, now.Add(DefaultPeriod)}, nil},
+		{"renew past due", State{StatusPastDue, periodEnd}, EventRenewed, time.Time{}, State{StatusActive, periodEnd.Add(DefaultPeriod)}, nil},
+		{"payment failed keeps period", State{StatusActive, periodEnd}, EventPaymentFailed, time.Time{}, State{StatusPastDue, periodEnd}, nil},
+		{"payment failed when inactive", State{StatusExpired, now.Add(-time.Hour)}, EventPaymentFailed, time.Time{}, State{StatusExpired, now.Add(-time.Hour)}, nil},
+		{"downgrade ends now", State{StatusActive, periodEnd}, EventDowngraded, time.Time{}, State{StatusCanceled, now}, nil},
+		{"refund ends now", State{StatusActive, periodEnd}, EventRefunded, time.Time{}, State{StatusRefunded, now}, nil},
+		{"unknown event", State{StatusActive, periodEnd}, "user.teleported", time.Time{}, State{StatusActive, periodEnd}, ErrUnknownEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.current, tt.event, tt.periodEnd, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, expected %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %+v, expected %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsActiveAndExpire(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		state      State
+		wantActive bool
+		wantStatus string
+	}{
+		{"active", State{StatusActive, now.Add(time.Hour)}, true, StatusActive},
+		{"active without end", State{StatusActive, time.Time{}}, true, StatusActive},
+		{"active lapsed", State{StatusActive, now}, false, StatusExpired},
+		{"past due in period", State{StatusPastDue, now.Add(time.Hour)}, true, StatusPastDue},
+		{"past due lapsed", State{StatusPastDue, now.Add(-time.Hour)}, false, StatusExpired},
+		{"canceled", State{StatusCanceled, now.Add(time.Hour)}, false, StatusCanceled},
+		{"refunded", State{StatusRefunded, now}, false, StatusRefunded},
+		{"never subscribed", State{}, false, StatusNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsActive(tt.state, now); got != tt.wantActive {
+				t.Errorf("IsActive() = %v, expected %v", got, tt.wantActive)
+			}
+			if got := Expire(tt.state, now).Status; got != tt.wantStatus {
+				t.Errorf("Expire() status = %q, expected %q", got, tt.wantStatus)
+			}
+		})
+	}
+}
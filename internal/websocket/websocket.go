@@ -0,0 +1,235 @@
+// Package websocket implements the server side of RFC 6455 for streams that
+// push text messages to clients. Messages from clients are read only to
+// answer pings and notice when the client goes away.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+
+	// maxFrameSize caps the frames accepted from clients, which have no
+	// reason to send anything large.
+	maxFrameSize = 64 << 10
+	// WriteTimeout bounds a single write to a client.
+	WriteTimeout = 10 * time.Second
+
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is an upgraded connection. WriteText and Close may be called from any
+// goroutine.
+type Conn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the opening handshake and takes over the connection.
+// On failure an error response has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response can't be hijacked")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", AcceptKey(key))
+	netConn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	c := &Conn{conn: netConn, rw: rw, done: make(chan struct{})}
+	go c.readLoop()
+	return c, nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept value answering key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Done is closed once the client closed the connection or it broke.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// WriteText sends data as a single text message.
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping, which keeps proxies from closing an idle connection.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a normal closure and closes the connection.
+func (c *Conn) Close() error {
+	c.writeFrame(opClose, []byte{0x03, 0xE8})
+	return c.shutdown()
+}
+
+func (c *Conn) shutdown() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *Conn) readLoop() {
+	defer close(c.done)
+	defer c.shutdown()
+	for {
+		opcode, payload, err := readFrame(c.rw.Reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return
+			}
+		case opClose:
+			c.writeFrame(opClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads one client frame and unmasks its payload. Fragmented
+// messages come back one fragment at a time, which is enough for a
+// connection that ignores data messages.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return 0, nil, errors.New("websocket: client frame is not masked")
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxFrameSize {
+		return 0, nil, errors.New("websocket: frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455, section 1.3.
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey() = %q", got)
+	}
+}
+
+func TestIsUpgrade(t *testing.T) {
+	tests := []struct {
+		connection string
+		upgrade    string
+		want       bool
+	}{
+		{"Upgrade", "websocket", true},
+		{"keep-alive, Upgrade", "WebSocket", true},
+		{"keep-alive", "websocket", false},
+		{"Upgrade", "h2c", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Connection", tt.connection)
+		r.Header.Set("Upgrade", tt.upgrade)
+		if got := IsUpgrade(r); got != tt.want {
+			t.Errorf("IsUpgrade(%q, %q) = %v, want %v", tt.connection, tt.upgrade, got, tt.want)
+		}
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	w := httptest.NewRecorder()
+	if _, err := Upgrade(w, httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Fatal("Upgrade() accepted a plain request")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestConversation(t *testing.T) {
+	serverDone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(serverDone)
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			t.Errorf("Upgrade() error = %v", err)
+			return
+		}
+		if err := conn.WriteText([]byte("hello")); err != nil {
+			t.Errorf("WriteText() error = %v", err)
+		}
+		select {
+		case <-conn.Done():
+		case <-time.After(5 * time.Second):
+			t.Error("Done() not closed after the client closed")
+		}
+	}))
+	defer server.Close()
+
+	client, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(client, "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	reader := bufio.NewReader(client)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake response = %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if opcode, payload := readServerFrame(t, reader); opcode != opText || string(payload) != "hello" {
+		t.Errorf("received opcode %d payload %q", opcode, payload)
+	}
+
+	writeClientFrame(t, client, opPing, []byte("ping"))
+	if opcode, payload := readServerFrame(t, reader); opcode != opPong || string(payload) != "ping" {
+		t.Errorf("ping answered with opcode %d payload %q", opcode, payload)
+	}
+
+	writeClientFrame(t, client, opClose, []byte{0x03, 0xE8})
+	if opcode, _ := readServerFrame(t, reader); opcode != opClose {
+		t.Errorf("close answered with opcode %d", opcode)
+	}
+	<-serverDone
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	payload := make([]byte, head[1]&0x7F)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+func writeClientFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := w.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFrameRejectsUnmaskedFrames(t *testing.T) {
+	frame := []byte{0x80 | opText, 2, 'h', 'i'}
+	if _, _, err := readFrame(bufio.NewReader(strings.NewReader(string(frame)))); err == nil {
+		t.Error("readFrame() accepted an unmasked frame")
+	}
+}
+
+func TestReadFrameRejectsLargeFrames(t *testing.T) {
+	frame := []byte{0x80 | opText, 0x80 | 127}
+	frame = binary.BigEndian.AppendUint64(frame, maxFrameSize+1)
+	if _, _, err := readFrame(bufio.NewReader(strings.NewReader(string(frame)))); err == nil {
+		t.Error("readFrame() accepted an oversized frame")
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"github.com/joho/godotenv"
 	"github.com/leonardoklaser/Chirpy/handlers"
+	"github.com/leonardoklaser/Chirpy/internal/auth"
 	"github.com/leonardoklaser/Chirpy/internal/config"
+	"github.com/leonardoklaser/Chirpy/internal/jobs"
 	_ "github.com/lib/pq"
 )
 
@@ -14,17 +22,197 @@ func configureRoutes(router *http.ServeMux, cfg *config.ApiConfig ){
 
 	router.HandleFunc("POST /admin/reset", cfg.HandleReset())
 
+	router.HandleFunc("GET /admin/api/users", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminListUsers)))
+
+	router.HandleFunc("DELETE /admin/api/users", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.DeleteUsers)))
+
+	router.HandleFunc("PUT /admin/api/users/{id}/role", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminSetUserRole)))
+
+	router.HandleFunc("POST /admin/api/users/{id}/suspend", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminSuspendUser)))
+
+	router.HandleFunc("POST /admin/api/users/{id}/unsuspend", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminUnsuspendUser)))
+
+	router.HandleFunc("POST /admin/api/users/{id}/unlock", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.UnlockUser)))
+
+	router.HandleFunc("GET /admin/api/audit", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminListAuditEvents)))
+
+	router.HandleFunc("GET /admin/api/audit/export", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminExportAuditEvents)))
+
+	router.HandleFunc("GET /admin/api/audit/verify", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminVerifyAuditLog)))
+
+	router.HandleFunc("GET /admin/api/reports", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminListReports)))
+
+	router.HandleFunc("POST /admin/api/reports/{id}/claim", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminClaimReport)))
+
+	router.HandleFunc("POST /admin/api/reports/{id}/resolve", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminResolveReport)))
+
+	router.HandleFunc("POST /admin/api/reports/{id}/dismiss", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminDismissReport)))
+
+	router.HandleFunc("GET /admin/api/webhook-events", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminListWebhookEvents)))
+
+	router.HandleFunc("POST /admin/api/webhooks", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminCreateWebhookEndpoint)))
+
+	router.HandleFunc("GET /admin/api/webhooks", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminListWebhookEndpoints)))
+
+	router.HandleFunc("DELETE /admin/api/webhooks/{id}", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminDeleteWebhookEndpoint)))
+
+	router.HandleFunc("GET /admin/api/webhooks/{id}/deliveries", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminListWebhookDeliveries)))
+
+	router.HandleFunc("POST /admin/api/webhooks/{id}/deliveries/{deliveryID}/replay", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleAdmin, handlers.AdminReplayWebhookDelivery)))
+
+	router.HandleFunc("DELETE /admin/api/chirps/{chirpID}", cfg.MiddlewareAuth(cfg.RequireRole(auth.RoleModerator, handlers.AdminDeleteChirp)))
+
 	router.HandleFunc("POST /api/validate_chirp", handlers.HandlerValidateChirp)
 
 	router.HandleFunc("POST /api/users", handlers.PostUser)
 
-	router.HandleFunc("POST /api/chirps", cfg.MiddlewareAuth(handlers.PostChirps))
+	router.HandleFunc("POST /api/users/verify", handlers.VerifyEmail)
+
+	router.HandleFunc("POST /api/users/verify/resend", cfg.MiddlewareAuth(handlers.ResendVerification))
+
+	router.HandleFunc("POST /api/password-reset", handlers.RequestPasswordReset)
+
+	router.HandleFunc("POST /api/password-reset/confirm", handlers.ConfirmPasswordReset)
+
+	router.HandleFunc("POST /api/chirps", cfg.MiddlewareAuthScope(auth.ScopeChirpsWrite, handlers.PostChirps))
+
+	router.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.MiddlewareAuthScope(auth.ScopeChirpsWrite, handlers.DeleteChirpById))
+
+	router.HandleFunc("GET /api/chirps", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.ListChirps))
+
+	router.HandleFunc("GET /api/chirps/{id}", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.GetChirp))
+
+	router.HandleFunc("GET /api/chirps/stream", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.StreamChirps))
+
+	router.HandleFunc("GET /api/chirps/stream/ws", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.StreamChirpsWebSocket))
+
+	router.HandleFunc("GET /api/chirps/drafts", cfg.MiddlewareAuthScope(auth.ScopeChirpsRead, handlers.ListDrafts))
+
+	router.HandleFunc("PUT /api/chirps/{id}", cfg.MiddlewareAuthScope(auth.ScopeChirpsWrite, handlers.UpdateDraft))
+
+	router.HandleFunc("PATCH /api/chirps/{id}", cfg.MiddlewareAuthScope(auth.ScopeChirpsWrite, handlers.EditChirp))
+
+	router.HandleFunc("POST /api/chirps/{id}/publish", cfg.MiddlewareAuthScope(auth.ScopeChirpsWrite, handlers.PublishDraft))
+
+	router.HandleFunc("POST /api/chirps/{id}/poll/vote", cfg.MiddlewareAuth(handlers.VotePoll))
+
+	router.HandleFunc("POST /api/media", cfg.MiddlewareAuthScope(auth.ScopeChirpsWrite, handlers.UploadMedia))
+
+	router.HandleFunc("GET /api/media/{id}", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.GetMedia))
+
+	router.HandleFunc("GET /api/media/{id}/thumbnail", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.GetMediaThumbnail))
+
+	router.HandleFunc("POST /api/chirps/{id}/report", cfg.MiddlewareAuth(handlers.ReportChirp))
+
+	router.HandleFunc("POST /api/chirps/{id}/bookmark", cfg.MiddlewareAuth(handlers.BookmarkChirp))
+
+	router.HandleFunc("DELETE /api/chirps/{id}/bookmark", cfg.MiddlewareAuth(handlers.UnbookmarkChirp))
+
+	router.HandleFunc("POST /api/chirps/{id}/like", cfg.MiddlewareAuth(handlers.LikeChirp))
+
+	router.HandleFunc("DELETE /api/chirps/{id}/like", cfg.MiddlewareAuth(handlers.UnlikeChirp))
+
+	router.HandleFunc("POST /api/chirps/{id}/pin", cfg.MiddlewareAuthScope(auth.ScopeProfileWrite, handlers.PinChirp))
+
+	router.HandleFunc("DELETE /api/chirps/{id}/pin", cfg.MiddlewareAuthScope(auth.ScopeProfileWrite, handlers.UnpinChirp))
+
+	router.HandleFunc("GET /api/users/{id}", cfg.MiddlewareOptionalAuthScope(auth.ScopeProfileRead, handlers.GetUserProfile))
+
+	router.HandleFunc("GET /api/users/by-handle/{handle}", cfg.MiddlewareOptionalAuthScope(auth.ScopeProfileRead, handlers.GetUserProfileByHandle))
+
+	router.HandleFunc("PATCH /api/users/me", cfg.MiddlewareAuthScope(auth.ScopeProfileWrite, handlers.UpdateProfile))
+
+	router.HandleFunc("POST /api/users/me/email", cfg.MiddlewareAuth(handlers.ChangeEmail))
+
+	router.HandleFunc("POST /api/users/me/password", cfg.MiddlewareAuth(handlers.ChangePassword))
+
+	router.HandleFunc("DELETE /api/users/me", cfg.MiddlewareAuth(handlers.DeleteAccount))
+
+	router.HandleFunc("POST /api/users/me/export", cfg.MiddlewareAuth(handlers.RequestDataExport))
+
+	router.HandleFunc("GET /api/users/me/exports/{id}", cfg.MiddlewareAuth(handlers.GetDataExport))
+
+	router.HandleFunc("GET /api/users/me/subscription", cfg.MiddlewareAuth(handlers.GetSubscription))
+
+	router.HandleFunc("GET /api/users/me/entitlements", cfg.MiddlewareAuth(handlers.GetEntitlements))
+
+	router.HandleFunc("GET /api/notifications", cfg.MiddlewareAuth(handlers.ListNotifications))
+
+	router.HandleFunc("POST /api/notifications/read", cfg.MiddlewareAuth(handlers.MarkNotificationsRead))
+
+	router.HandleFunc("GET /api/notifications/preferences", cfg.MiddlewareAuth(handlers.GetNotificationPreferences))
 
-	router.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.MiddlewareAuth(handlers.DeleteChirpById))
+	router.HandleFunc("PUT /api/notifications/preferences", cfg.MiddlewareAuth(handlers.UpdateNotificationPreferences))
 
-	router.HandleFunc("GET /api/chirps", handlers.ListChirps)
+	router.HandleFunc("GET /api/notifications/stream", cfg.MiddlewareAuth(handlers.StreamNotifications))
 
-	router.HandleFunc("GET /api/chirps/{id}", handlers.GetChirp)
+	router.HandleFunc("GET /api/users/me/messaging", cfg.MiddlewareAuth(handlers.GetMessagingSettings))
+
+	router.HandleFunc("PUT /api/users/me/messaging", cfg.MiddlewareAuth(handlers.UpdateMessagingSettings))
+
+	router.HandleFunc("GET /api/users/me/bookmarks", cfg.MiddlewareAuth(handlers.ListBookmarks))
+
+	router.HandleFunc("GET /api/users/me/lists", cfg.MiddlewareAuth(handlers.ListMyLists))
+
+	router.HandleFunc("POST /api/conversations", cfg.MiddlewareAuth(handlers.CreateConversation))
+
+	router.HandleFunc("GET /api/conversations", cfg.MiddlewareAuth(handlers.ListConversations))
+
+	router.HandleFunc("GET /api/conversations/stream", cfg.MiddlewareAuth(handlers.StreamMessages))
+
+	router.HandleFunc("GET /api/conversations/{id}/messages", cfg.MiddlewareAuth(handlers.ListMessages))
+
+	router.HandleFunc("POST /api/conversations/{id}/messages", cfg.MiddlewareAuth(handlers.SendMessage))
+
+	router.HandleFunc("POST /api/conversations/{id}/read", cfg.MiddlewareAuth(handlers.MarkConversationRead))
+
+	router.HandleFunc("POST /api/webhooks", cfg.MiddlewareAuth(handlers.CreateWebhookEndpoint))
+
+	router.HandleFunc("GET /api/webhooks", cfg.MiddlewareAuth(handlers.ListWebhookEndpoints))
+
+	router.HandleFunc("DELETE /api/webhooks/{id}", cfg.MiddlewareAuth(handlers.DeleteWebhookEndpoint))
+
+	router.HandleFunc("GET /api/webhooks/{id}/deliveries", cfg.MiddlewareAuth(handlers.ListWebhookDeliveries))
+
+	router.HandleFunc("POST /api/webhooks/{id}/deliveries/{deliveryID}/replay", cfg.MiddlewareAuth(handlers.ReplayWebhookDelivery))
+
+	router.HandleFunc("GET /api/exports/{id}/download", handlers.DownloadDataExport)
+
+	router.HandleFunc("POST /api/users/{id}/follow", cfg.MiddlewareAuth(handlers.FollowUser))
+
+	router.HandleFunc("DELETE /api/users/{id}/follow", cfg.MiddlewareAuth(handlers.UnfollowUser))
+
+	router.HandleFunc("POST /api/users/{id}/report", cfg.MiddlewareAuth(handlers.ReportUser))
+
+	router.HandleFunc("POST /api/users/{id}/block", cfg.MiddlewareAuth(handlers.BlockUser))
+
+	router.HandleFunc("DELETE /api/users/{id}/block", cfg.MiddlewareAuth(handlers.UnblockUser))
+
+	router.HandleFunc("GET /api/blocks", cfg.MiddlewareAuth(handlers.ListBlocks))
+
+	router.HandleFunc("POST /api/users/{id}/mute", cfg.MiddlewareAuth(handlers.MuteUser))
+
+	router.HandleFunc("DELETE /api/users/{id}/mute", cfg.MiddlewareAuth(handlers.UnmuteUser))
+
+	router.HandleFunc("GET /api/mutes", cfg.MiddlewareAuth(handlers.ListMutes))
+
+	router.HandleFunc("POST /api/lists", cfg.MiddlewareAuth(handlers.CreateList))
+
+	router.HandleFunc("GET /api/lists", cfg.MiddlewareOptionalAuthScope(auth.ScopeProfileRead, handlers.ListUserLists))
+
+	router.HandleFunc("GET /api/lists/{id}", cfg.MiddlewareOptionalAuthScope(auth.ScopeProfileRead, handlers.GetList))
+
+	router.HandleFunc("PUT /api/lists/{id}", cfg.MiddlewareAuth(handlers.UpdateList))
+
+	router.HandleFunc("DELETE /api/lists/{id}", cfg.MiddlewareAuth(handlers.DeleteList))
+
+	router.HandleFunc("GET /api/lists/{id}/chirps", cfg.MiddlewareOptionalAuthScope(auth.ScopeChirpsRead, handlers.ListListChirps))
+
+	router.HandleFunc("GET /api/lists/{id}/members", cfg.MiddlewareOptionalAuthScope(auth.ScopeProfileRead, handlers.ListListMembers))
+
+	router.HandleFunc("PUT /api/lists/{id}/members/{userID}", cfg.MiddlewareAuth(handlers.AddListMember))
+
+	router.HandleFunc("DELETE /api/lists/{id}/members/{userID}", cfg.MiddlewareAuth(handlers.RemoveListMember))
 
 	router.HandleFunc("POST /api/login", handlers.LoginUser)
 
@@ -34,9 +222,56 @@ func configureRoutes(router *http.ServeMux, cfg *config.ApiConfig ){
 
 	router.HandleFunc("PUT /api/users", cfg.MiddlewareAuth(handlers.UpdateUser))
 
+	router.HandleFunc("POST /api/tokens", cfg.MiddlewareAuth(handlers.CreatePersonalAccessToken))
+
+	router.HandleFunc("GET /api/tokens", cfg.MiddlewareAuth(handlers.ListPersonalAccessTokens))
+
+	router.HandleFunc("DELETE /api/tokens/{id}", cfg.MiddlewareAuth(handlers.RevokePersonalAccessToken))
+
+	router.HandleFunc("POST /api/oauth/clients", cfg.MiddlewareAuth(handlers.RegisterOAuthClient))
+
+	router.HandleFunc("GET /api/oauth/consents", cfg.MiddlewareAuth(handlers.ListOAuthConsents))
+
+	router.HandleFunc("DELETE /api/oauth/consents/{clientID}", cfg.MiddlewareAuth(handlers.RevokeOAuthConsent))
+
+	router.HandleFunc("GET /oauth/authorize", handlers.OAuthAuthorizePage)
+
+	router.HandleFunc("POST /oauth/authorize", handlers.OAuthAuthorize)
+
+	router.HandleFunc("POST /oauth/token", handlers.OAuthToken)
+
+	router.HandleFunc("POST /oauth/revoke", handlers.OAuthRevoke)
+
 	router.HandleFunc("POST /api/polka/webhooks", cfg.MiddlewarePolka(handlers.PolkaWebhook))
 }
 
+// schedule enqueues a job of kind every interval, to run at runAt(now). The
+// kind doubles as the unique key, so however many servers are running there
+// is only ever one run waiting.
+func schedule(ctx context.Context, client *jobs.Client, kind string, interval time.Duration, runAt func(time.Time) time.Time) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := client.Enqueue(ctx, kind, struct{}{}, jobs.Unique(kind), jobs.RunAt(runAt(time.Now().UTC())))
+		if err != nil {
+			log.Printf("Error scheduling %s: %v", kind, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// immediately runs a scheduled job as soon as it is enqueued.
+func immediately(t time.Time) time.Time { return t }
+
+// nextMidnight returns the next midnight UTC after t.
+func nextMidnight(t time.Time) time.Time {
+	return t.Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -53,15 +288,64 @@ func main() {
 
 	configureRoutes(router, cfg)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := jobs.NewRunner(jobs.NewPostgresStore(cfg.DB), cfg.JobWorkers)
+	handlers.RegisterJobs(runner, cfg)
+	runnerDone := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(runnerDone)
+	}()
+
+	go schedule(ctx, cfg.Jobs, handlers.JobPurgeDeletedAccounts, 10*time.Minute, immediately)
+	go schedule(ctx, cfg.Jobs, handlers.JobPurgeDetachedMedia, 10*time.Minute, immediately)
+	go schedule(ctx, cfg.Jobs, handlers.JobPurgeDeadJobs, time.Hour, immediately)
+	go schedule(ctx, cfg.Jobs, handlers.JobPublishScheduledChirps, 30*time.Second, immediately)
+	go schedule(ctx, cfg.Jobs, handlers.JobClosePolls, 30*time.Second, immediately)
+	go schedule(ctx, cfg.Jobs, handlers.JobDeliverWebhooks, 5*time.Second, immediately)
+	go schedule(ctx, cfg.Jobs, handlers.JobExpireSubscriptions, time.Hour, nextMidnight)
+	go schedule(ctx, cfg.Jobs, handlers.JobPurgeChirpEvents, time.Hour, immediately)
+
+	go func() {
+		if err := handlers.RelayChirpEvents(ctx, cfg); err != nil {
+			log.Printf("Error relaying chirp events, the live stream is down: %v", err)
+		}
+	}()
+	go func() {
+		if err := handlers.RelayMessages(ctx, cfg); err != nil {
+			log.Printf("Error relaying messages, the message stream is down: %v", err)
+		}
+	}()
+
 	server := &http.Server{
 		Addr:    ":8080",
-		Handler: router,
+		Handler: cfg.MiddlewareRequestID(router),
 	}
+	// Notification streams never finish on their own, so end them on shutdown.
+	server.RegisterOnShutdown(cfg.Notifications.Close)
+	server.RegisterOnShutdown(cfg.ChirpEvents.Close)
+	server.RegisterOnShutdown(cfg.Messages.Close)
 
-	log.Println("Starting server on :8080")
-	err = server.ListenAndServe()
-	if err != nil {
-		log.Fatal(err)
-	}
+	go func() {
+		log.Println("Starting server on :8080")
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
+	<-ctx.Done()
+	log.Println("Shutting down, waiting for requests and running jobs to finish")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	select {
+	case <-runnerDone:
+	case <-shutdownCtx.Done():
+		log.Println("Gave up waiting for running jobs, they will be retried")
+	}
 }
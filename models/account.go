@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccountDeletion struct {
+	RequestedAt time.Time `json:"requested_at"`
+	PurgeAfter  time.Time `json:"purge_after"`
+}
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+// Session is a refresh token as shown in a data export, without the token.
+type Session struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Like is a chirp the user liked, as shown in a data export.
+type Like struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
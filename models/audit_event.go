@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEvent struct {
+	Seq        int64           `json:"seq"`
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	Details    json.RawMessage `json:"details"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
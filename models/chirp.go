@@ -6,9 +6,18 @@ import (
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserId    uuid.UUID `json:"user_id"`
-}
\ No newline at end of file
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Body      string       `json:"body"`
+	UserId    uuid.UUID    `json:"user_id"`
+	Hidden    bool         `json:"hidden,omitempty"`
+	Status    string       `json:"status"`
+	PublishAt *time.Time   `json:"publish_at,omitempty"`
+	EditedAt  *time.Time   `json:"edited_at,omitempty"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id,omitempty"`
+	Pinned    bool         `json:"pinned,omitempty"`
+	Badge     string       `json:"badge,omitempty"`
+	Media     []Attachment `json:"media,omitempty"`
+	Poll      *Poll        `json:"poll,omitempty"`
+}
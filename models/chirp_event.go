@@ -0,0 +1,12 @@
+package models
+
+import "encoding/json"
+
+// ChirpStreamMessage is a chirp stream event as sent over WebSocket. Data is
+// the chirp for chirp.created, its id and user_id for chirp.deleted, and an
+// empty object for stream.reset.
+type ChirpStreamMessage struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
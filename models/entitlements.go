@@ -0,0 +1,10 @@
+package models
+
+type Entitlements struct {
+	ChirpyRed        bool   `json:"is_chirpy_red"`
+	MaxChirpLength   int    `json:"max_chirp_length"`
+	MaxMediaPerChirp int    `json:"max_media_per_chirp"`
+	ChirpsPerHour    int    `json:"chirps_per_hour"`
+	CanEditChirps    bool   `json:"can_edit_chirps"`
+	Badge            string `json:"badge,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "github.com/google/uuid"
+
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Conversation struct {
+	ID          uuid.UUID   `json:"id"`
+	IsGroup     bool        `json:"is_group"`
+	MemberIDs   []uuid.UUID `json:"member_ids"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	UnreadCount int64       `json:"unread_count"`
+	LastMessage *Message    `json:"last_message,omitempty"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type MessagingSettings struct {
+	DMPolicy string `json:"dm_policy"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+type NotificationList struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Poll is the poll on a chirp. Votes and TotalVotes are left out until the
+// viewer has voted or the poll has closed.
+type Poll struct {
+	Options     []PollOption `json:"options"`
+	ClosesAt    time.Time    `json:"closes_at"`
+	Closed      bool         `json:"closed"`
+	VotedOption *int32       `json:"voted_option,omitempty"`
+	TotalVotes  *int64       `json:"total_votes,omitempty"`
+}
+
+type PollOption struct {
+	Label string `json:"label"`
+	Votes *int64 `json:"votes,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Profile is the public view of a user. It must never carry the email or
+// anything else private to the account.
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle,omitempty"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	Location       string    `json:"location"`
+	Website        string    `json:"website"`
+	AvatarURL      string    `json:"avatar_url"`
+	ChirpyRed      bool      `json:"is_chirpy_red"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	ChirpCount     int64     `json:"chirp_count"`
+}
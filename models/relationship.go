@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Relationship is a user the caller has blocked or muted.
+type Relationship struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Report struct {
+	ID             uuid.UUID  `json:"id"`
+	ReporterID     uuid.UUID  `json:"reporter_id"`
+	TargetType     string     `json:"target_type"`
+	TargetID       uuid.UUID  `json:"target_id"`
+	ReportedUserID uuid.UUID  `json:"reported_user_id"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details"`
+	Status         string     `json:"status"`
+	ClaimedBy      *uuid.UUID `json:"claimed_by,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	ResolvedBy     *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	Resolution     string     `json:"resolution,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Subscription struct {
+	Status           string              `json:"status"`
+	CurrentPeriodEnd *time.Time          `json:"current_period_end,omitempty"`
+	ChirpyRed        bool                `json:"is_chirpy_red"`
+	History          []SubscriptionEvent `json:"history"`
+}
+
+type SubscriptionEvent struct {
+	EventType        string     `json:"event_type"`
+	OldStatus        string     `json:"old_status"`
+	NewStatus        string     `json:"new_status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
@@ -13,4 +13,14 @@ type User struct {
 		Token string `json:"token"`
 		Refresh_token string `json:"refresh_token"`
 		ChirpyRed bool `json:"is_chirpy_red"`
+		EmailVerified bool `json:"email_verified"`
+		Role string `json:"role,omitempty"`
+		SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+		SuspendedReason string `json:"suspended_reason,omitempty"`
+		Handle string `json:"handle,omitempty"`
+		DisplayName string `json:"display_name,omitempty"`
+		Bio string `json:"bio,omitempty"`
+		Location string `json:"location,omitempty"`
+		Website string `json:"website,omitempty"`
+		AvatarURL string `json:"avatar_url,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type WebhookEvent struct {
+	Source      string     `json:"source"`
+	ID          string     `json:"id"`
+	EventType   string     `json:"event_type"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Attempts    int32      `json:"attempts"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
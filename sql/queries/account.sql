@@ -0,0 +1,35 @@
+-- name: ScheduleAccountDeletion :one
+INSERT INTO account_deletions (user_id, requested_at, purge_after)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (user_id) DO UPDATE SET purge_after = account_deletions.purge_after
+RETURNING *;
+
+-- name: CancelAccountDeletion :execresult
+DELETE FROM account_deletions WHERE user_id = $1;
+
+-- name: ListDueAccountDeletions :many
+SELECT * FROM account_deletions WHERE purge_after <= NOW() ORDER BY purge_after LIMIT $1;
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, user_id, status, created_at)
+VALUES (gen_random_uuid(), $1, 'pending', NOW())
+ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = $1;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports SET status = 'ready', file_path = $2, completed_at = NOW(), expires_at = $3 WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports SET status = 'failed', error = $2, completed_at = NOW() WHERE id = $1;
+
+-- name: ListDataExportFilesForUser :many
+SELECT file_path FROM data_exports WHERE user_id = $1 AND file_path <> '';
+
+-- name: ListExpiredDataExports :many
+SELECT * FROM data_exports WHERE expires_at <= NOW();
+
+-- name: DeleteDataExport :exec
+DELETE FROM data_exports WHERE id = $1;
@@ -0,0 +1,5 @@
+-- name: CreateAdminAction :exec
+INSERT INTO admin_actions (id, actor_id, action, target_type, target_id, details, created_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, $5, NOW()
+);
@@ -0,0 +1,24 @@
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_events'));
+
+-- name: GetLastAuditHash :one
+SELECT hash FROM audit_events ORDER BY seq DESC LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (id, created_at, action, actor_id, target_type, target_id, ip, request_id, details, prev_hash, hash)
+VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action')::text)
+  AND (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id')::uuid)
+  AND (sqlc.narg('target_type')::text IS NULL OR target_type = sqlc.narg('target_type')::text)
+  AND (sqlc.narg('target_id')::text IS NULL OR target_id = sqlc.narg('target_id')::text)
+  AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since')::timestamp)
+  AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until')::timestamp)
+  AND seq > sqlc.arg('after_seq')
+ORDER BY seq ASC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,36 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBlock :execresult
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: ListBlocks :many
+SELECT * FROM blocks WHERE blocker_id = $1 ORDER BY created_at DESC;
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+);
+
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteMute :execresult
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: ListMutes :many
+SELECT * FROM mutes WHERE muter_id = $1 ORDER BY created_at DESC;
+
+-- name: ListTimelineExclusions :many
+-- Lists the users kept off viewer's timeline: users who blocked viewer or
+-- whom viewer blocked or muted.
+SELECT blocked_id AS user_id, false AS muted FROM blocks WHERE blocker_id = sqlc.arg('viewer_id')
+UNION ALL
+SELECT blocker_id AS user_id, false AS muted FROM blocks WHERE blocked_id = sqlc.arg('viewer_id')
+UNION ALL
+SELECT muted_id AS user_id, true AS muted FROM mutes WHERE muter_id = sqlc.arg('viewer_id');
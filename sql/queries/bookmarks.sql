@@ -0,0 +1,24 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBookmark :execresult
+DELETE FROM bookmarks WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListBookmarkedChirps :many
+-- Lists the bookmarked chirps the user can still see, in the order they
+-- were bookmarked.
+SELECT c.* FROM bookmarks b
+JOIN chirps c ON c.id = b.chirp_id
+WHERE b.user_id = sqlc.arg('user_id')
+  AND ((c.hidden_at IS NULL AND c.status = 'published') OR c.user_id = sqlc.arg('user_id'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = c.user_id AND blocked_id = sqlc.arg('user_id'))
+       OR (blocker_id = sqlc.arg('user_id') AND blocked_id = c.user_id)
+  )
+ORDER BY
+  CASE WHEN sqlc.arg('newest_first')::boolean THEN b.created_at END DESC,
+  CASE WHEN NOT sqlc.arg('newest_first')::boolean THEN b.created_at END ASC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
@@ -0,0 +1,23 @@
+-- name: LockChirpEvents :exec
+-- Held while inserting an event, so events commit in seq order and a reader
+-- that has seen one seq has seen every smaller one.
+SELECT pg_advisory_xact_lock(hashtext('chirp_events'));
+
+-- name: CreateChirpEvent :exec
+INSERT INTO chirp_events (type, chirp_id, user_id, hashtags, payload)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetChirpEvent :one
+SELECT * FROM chirp_events WHERE seq = $1;
+
+-- name: ListChirpEventsAfter :many
+SELECT * FROM chirp_events WHERE seq > $1 ORDER BY seq LIMIT $2;
+
+-- name: GetLatestChirpEventSeq :one
+SELECT COALESCE(MAX(seq), 0)::bigint AS seq FROM chirp_events;
+
+-- name: GetOldestChirpEventSeq :one
+SELECT COALESCE(MIN(seq), 0)::bigint AS seq FROM chirp_events;
+
+-- name: DeleteChirpEventsBefore :execresult
+DELETE FROM chirp_events WHERE created_at < $1;
@@ -1,19 +1,111 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
 )
 RETURNING *;
 
 
 -- name: GetAllChirps :many
-SELECT * FROM chirps;
+SELECT * FROM chirps
+WHERE ((hidden_at IS NULL AND status = 'published') OR user_id = sqlc.narg('viewer_id'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = sqlc.narg('viewer_id'))
+       OR (blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE muter_id = sqlc.narg('viewer_id') AND muted_id = chirps.user_id
+  )
+ORDER BY created_at;
 
 -- name: GetChirpById :one
 SELECT * FROM chirps WHERE id = $1;
 
+-- name: GetVisibleChirpById :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg('id') AND ((hidden_at IS NULL AND status = 'published') OR user_id = sqlc.narg('viewer_id'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = chirps.user_id AND blocked_id = sqlc.narg('viewer_id')
+  );
+
 -- name: DeleteChirpById :execresult
 DELETE FROM chirps WHERE id = $1;
 
 -- name: GetChirpsByUserId :many
-SELECT * FROM chirps WHERE user_id = $1;
+-- Lists the chirps and replies of a user, their pinned chirp first.
+SELECT sqlc.embed(chirps), (pinned_chirps.chirp_id IS NOT NULL)::boolean AS pinned
+FROM chirps
+LEFT JOIN pinned_chirps ON pinned_chirps.chirp_id = chirps.id
+WHERE chirps.user_id = sqlc.arg('user_id')
+  AND ((chirps.hidden_at IS NULL AND chirps.status = 'published') OR chirps.user_id = sqlc.narg('viewer_id'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = sqlc.narg('viewer_id'))
+       OR (blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  )
+ORDER BY pinned DESC, chirps.created_at;
+
+-- name: GetChirpsByUserIdWithoutReplies :many
+-- Lists the chirps of a user, leaving out their replies, their pinned chirp
+-- first.
+SELECT sqlc.embed(chirps), (pinned_chirps.chirp_id IS NOT NULL)::boolean AS pinned
+FROM chirps
+LEFT JOIN pinned_chirps ON pinned_chirps.chirp_id = chirps.id
+WHERE chirps.user_id = sqlc.arg('user_id') AND chirps.reply_to_id IS NULL
+  AND ((chirps.hidden_at IS NULL AND chirps.status = 'published') OR chirps.user_id = sqlc.narg('viewer_id'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = sqlc.narg('viewer_id'))
+       OR (blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  )
+ORDER BY pinned DESC, chirps.created_at;
+
+-- name: GetMediaChirpsByUserId :many
+-- Lists the chirps of a user that have media attached.
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg('user_id') AND ((hidden_at IS NULL AND status = 'published') OR user_id = sqlc.narg('viewer_id'))
+  AND EXISTS (
+    SELECT 1 FROM media_attachments WHERE media_attachments.chirp_id = chirps.id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = sqlc.narg('viewer_id'))
+       OR (blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  )
+ORDER BY created_at;
+
+-- name: CountChirpsByUserId :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1;
+
+-- name: HideChirp :execresult
+UPDATE chirps SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: ListUnpublishedChirps :many
+SELECT * FROM chirps WHERE user_id = $1 AND status <> 'published' ORDER BY updated_at DESC;
+
+-- name: UpdateUnpublishedChirp :one
+UPDATE chirps SET body = $3, status = $4, publish_at = $5, updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status <> 'published'
+RETURNING *;
+
+-- name: PublishChirp :one
+UPDATE chirps SET status = 'published', publish_at = NULL, created_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status <> 'published'
+RETURNING *;
+
+-- name: PublishDueChirps :many
+-- Publishes scheduled chirps whose time has come. created_at becomes the
+-- scheduled time, so chirps published late after downtime keep their place.
+UPDATE chirps SET status = 'published', created_at = publish_at, publish_at = NULL, updated_at = NOW()
+WHERE status = 'scheduled' AND publish_at <= NOW()
+RETURNING *;
+
+-- name: EditPublishedChirp :one
+-- A chirp hidden by a moderator can't be edited back into view.
+UPDATE chirps SET body = $3, edited_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status = 'published' AND hidden_at IS NULL
+RETURNING *;
+
+-- name: CountChirpsSince :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND created_at > sqlc.arg('since');
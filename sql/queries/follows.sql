@@ -0,0 +1,14 @@
+-- name: CreateFollow :execresult
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollow :execresult
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = $1 AND followee_id = $2) OR (follower_id = $2 AND followee_id = $1);
+
+-- name: ListFollowsForUser :many
+SELECT * FROM follows WHERE follower_id = $1 OR followee_id = $1 ORDER BY created_at DESC;
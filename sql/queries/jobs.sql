@@ -0,0 +1,30 @@
+-- name: InsertJob :execresult
+INSERT INTO jobs (id, kind, payload, status, max_attempts, run_at, unique_key, created_at)
+VALUES ($1, $2, $3, 'pending', $4, $5, $6, NOW())
+ON CONFLICT (unique_key) WHERE status IN ('pending', 'running') DO NOTHING;
+
+-- name: ClaimJobs :many
+-- Hands out due jobs, and running jobs whose lease expired. SKIP LOCKED lets
+-- workers on several servers claim jobs side by side.
+UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = sqlc.arg('locked_until')
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE kind = ANY(sqlc.arg('kinds')::text[])
+      AND ((status = 'pending' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+    ORDER BY run_at
+    LIMIT sqlc.arg('max_jobs')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+DELETE FROM jobs WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs SET status = 'pending', run_at = $2, last_error = $3, locked_until = NULL WHERE id = $1;
+
+-- name: KillJob :exec
+UPDATE jobs SET status = 'dead', last_error = $2, locked_until = NULL WHERE id = $1;
+
+-- name: DeleteDeadJobs :exec
+DELETE FROM jobs WHERE status = 'dead' AND created_at < $1;
@@ -0,0 +1,24 @@
+-- name: CreateLike :exec
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteLike :execresult
+DELETE FROM likes WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetChirpsLikedByUser :many
+-- Lists the chirps a user liked that viewer can see, most recently liked
+-- first. Nothing is listed when the user and viewer have blocked one another.
+SELECT c.* FROM likes l
+JOIN chirps c ON c.id = l.chirp_id
+WHERE l.user_id = sqlc.arg('user_id')
+  AND ((c.hidden_at IS NULL AND c.status = 'published') OR c.user_id = sqlc.narg('viewer_id'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id IN (c.user_id, l.user_id) AND blocked_id = sqlc.narg('viewer_id'))
+       OR (blocker_id = sqlc.narg('viewer_id') AND blocked_id IN (c.user_id, l.user_id))
+  )
+ORDER BY l.created_at DESC;
+
+-- name: ListLikesForUser :many
+SELECT user_id, chirp_id, created_at FROM likes WHERE user_id = $1 ORDER BY created_at DESC;
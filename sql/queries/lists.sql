@@ -0,0 +1,59 @@
+-- name: CreateList :one
+INSERT INTO lists (id, owner_id, name, description, is_private, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: CountListsByOwner :one
+SELECT COUNT(*) FROM lists WHERE owner_id = $1;
+
+-- name: GetList :one
+SELECT * FROM lists WHERE id = $1;
+
+-- name: ListListsByOwner :many
+-- Lists the lists of owner, newest first. Private lists are left out unless
+-- include_private is set.
+SELECT * FROM lists
+WHERE owner_id = sqlc.arg('owner_id') AND (sqlc.arg('include_private')::boolean OR NOT is_private)
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: UpdateList :one
+UPDATE lists SET name = $2, description = $3, is_private = $4, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteList :exec
+DELETE FROM lists WHERE id = $1;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :execresult
+DELETE FROM list_members WHERE list_id = $1 AND user_id = $2;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members WHERE list_id = $1;
+
+-- name: ListListMembers :many
+SELECT user_id FROM list_members WHERE list_id = $1 ORDER BY created_at LIMIT $2 OFFSET $3;
+
+-- name: ListChirpsForList :many
+-- Lists the published chirps of a list's members, leaving out the authors
+-- viewer blocked, was blocked by or muted.
+SELECT c.* FROM chirps c
+JOIN list_members m ON m.user_id = c.user_id
+WHERE m.list_id = sqlc.arg('list_id') AND c.hidden_at IS NULL AND c.status = 'published'
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = c.user_id AND blocked_id = sqlc.narg('viewer_id'))
+       OR (blocker_id = sqlc.narg('viewer_id') AND blocked_id = c.user_id)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE muter_id = sqlc.narg('viewer_id') AND muted_id = c.user_id
+  )
+ORDER BY
+  CASE WHEN sqlc.arg('newest_first')::boolean THEN c.created_at END DESC,
+  CASE WHEN NOT sqlc.arg('newest_first')::boolean THEN c.created_at END ASC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
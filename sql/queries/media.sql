@@ -0,0 +1,27 @@
+-- name: CreateMediaAttachment :one
+INSERT INTO media_attachments (id, user_id, content_type, size_bytes, width, height, storage_key, thumbnail_key, thumbnail_type, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+RETURNING *;
+
+-- name: GetMediaAttachment :one
+SELECT * FROM media_attachments WHERE id = $1;
+
+-- name: AttachMediaToChirp :execresult
+-- Attaches the caller's detached uploads to a chirp, in the order given.
+UPDATE media_attachments
+SET chirp_id = sqlc.arg(chirp_id), position = array_position(sqlc.arg(media_ids)::uuid[], id)
+WHERE id = ANY(sqlc.arg(media_ids)::uuid[]) AND user_id = sqlc.arg(user_id) AND chirp_id IS NULL;
+
+-- name: ListMediaForChirps :many
+SELECT * FROM media_attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: ListDetachedMedia :many
+SELECT * FROM media_attachments WHERE chirp_id IS NULL AND created_at < $1 ORDER BY created_at LIMIT 500;
+
+-- name: ListMediaForUser :many
+SELECT * FROM media_attachments WHERE user_id = $1;
+
+-- name: DeleteMediaAttachment :exec
+DELETE FROM media_attachments WHERE id = $1;
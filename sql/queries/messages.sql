@@ -0,0 +1,115 @@
+-- name: GetMessagingPolicy :one
+SELECT COALESCE((SELECT dm_policy FROM messaging_settings WHERE user_id = $1), 'everyone')::text AS dm_policy;
+
+-- name: UpsertMessagingPolicy :exec
+INSERT INTO messaging_settings (user_id, dm_policy)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET dm_policy = EXCLUDED.dm_policy;
+
+-- name: ListMessagingPermissions :many
+-- Returns what decides whether sender may start a conversation with each
+-- recipient: their policy, whether they follow sender, and blocks either way.
+SELECT u.id,
+    COALESCE(s.dm_policy, 'everyone')::text AS dm_policy,
+    EXISTS (
+        SELECT 1 FROM follows f WHERE f.follower_id = u.id AND f.followee_id = sqlc.arg(sender_id)
+    ) AS follows_sender,
+    EXISTS (
+        SELECT 1 FROM blocks b
+        WHERE (b.blocker_id = u.id AND b.blocked_id = sqlc.arg(sender_id))
+           OR (b.blocker_id = sqlc.arg(sender_id) AND b.blocked_id = u.id)
+    ) AS blocked
+FROM users u
+LEFT JOIN messaging_settings s ON s.user_id = u.id
+WHERE u.id = ANY(sqlc.arg(recipient_ids)::uuid[]);
+
+-- name: HasBlocksAmong :one
+-- Reports whether any of the users blocked another of them.
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocker_id = ANY(sqlc.arg(user_ids)::uuid[]) AND blocked_id = ANY(sqlc.arg(user_ids)::uuid[])
+)::boolean AS blocked;
+
+-- name: CreateConversation :one
+-- Returns no row when a one-to-one conversation with the same direct_key
+-- already exists.
+INSERT INTO conversations (id, created_by, is_group, direct_key, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW())
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations WHERE direct_key = $1;
+
+-- name: AddConversationMembers :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+SELECT sqlc.arg(conversation_id)::uuid, unnest(sqlc.arg(user_ids)::uuid[]), NOW()
+ON CONFLICT DO NOTHING;
+
+-- name: GetConversationForMember :one
+SELECT c.* FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE c.id = $1 AND m.user_id = $2;
+
+-- name: ListConversationsForUser :many
+SELECT c.id, c.created_by, c.is_group, c.direct_key, c.created_at, c.updated_at, m.last_read_at,
+    (
+        SELECT COUNT(*) FROM messages msg
+        WHERE msg.conversation_id = c.id AND msg.sender_id <> m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at)
+          AND NOT EXISTS (
+            SELECT 1 FROM blocks
+            WHERE (blocker_id = m.user_id AND blocked_id = msg.sender_id)
+               OR (blocker_id = msg.sender_id AND blocked_id = m.user_id)
+          )
+    ) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = $1
+ORDER BY c.updated_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListConversationMembers :many
+SELECT conversation_id, user_id FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_id, joined_at, user_id;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW() WHERE id = $1;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+RETURNING *;
+
+-- name: GetMessage :one
+SELECT * FROM messages WHERE id = $1;
+
+-- name: ListMessages :many
+-- Lists a conversation's messages, newest first, leaving out the senders
+-- viewer blocked or was blocked by.
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(viewer_id) AND blocked_id = messages.sender_id)
+       OR (blocker_id = messages.sender_id AND blocked_id = sqlc.arg(viewer_id))
+  )
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ListLatestMessages :many
+-- Returns the latest message of each conversation that viewer can see,
+-- leaving out the senders viewer blocked or was blocked by.
+SELECT DISTINCT ON (conversation_id) * FROM messages
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(viewer_id) AND blocked_id = messages.sender_id)
+       OR (blocker_id = messages.sender_id AND blocked_id = sqlc.arg(viewer_id))
+  )
+ORDER BY conversation_id, created_at DESC;
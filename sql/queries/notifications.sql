@@ -0,0 +1,37 @@
+-- name: CreateNotification :one
+-- Creates a notification unless the recipient turned the type off, or blocked
+-- or muted the actor.
+INSERT INTO notifications (id, user_id, type, actor_id, chirp_id, created_at)
+SELECT gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('type'), sqlc.narg('actor_id'), sqlc.narg('chirp_id'), NOW()
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences p
+    WHERE p.user_id = sqlc.arg('user_id') AND p.type = sqlc.arg('type') AND NOT p.enabled
+) AND NOT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = sqlc.arg('user_id') AND blocked_id = sqlc.narg('actor_id')
+) AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE muter_id = sqlc.arg('user_id') AND muted_id = sqlc.narg('actor_id')
+)
+RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg('user_id') AND (NOT sqlc.arg('unread_only')::boolean OR read_at IS NULL)
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execresult
+-- Marks the given notifications as read, or all of them when ids is empty.
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND read_at IS NULL
+  AND (cardinality(sqlc.arg('ids')::uuid[]) = 0 OR id = ANY(sqlc.arg('ids')::uuid[]));
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
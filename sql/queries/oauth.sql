@@ -0,0 +1,61 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, secret_hash, name, redirect_uris, owner_id, created_at)
+VALUES (
+    $1, $2, $3, $4, $5, NOW()
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+    $1, $2, $3, $4, $5, $6, NOW(), $7
+);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes SET used_at = NOW()
+WHERE code_hash = $1
+  AND client_id = $2
+  AND used_at IS NULL
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: UpsertOAuthConsent :exec
+INSERT INTO oauth_consents (user_id, client_id, scopes, created_at, updated_at)
+VALUES (
+    $1, $2, $3, NOW(), NOW()
+)
+ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = NOW();
+
+-- name: ListOAuthConsents :many
+SELECT c.user_id, c.client_id, c.scopes, c.created_at, c.updated_at, oc.name AS client_name
+FROM oauth_consents c
+INNER JOIN oauth_clients oc ON oc.id = c.client_id
+WHERE c.user_id = $1
+ORDER BY c.updated_at DESC;
+
+-- name: DeleteOAuthConsent :execresult
+DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, client_id, scopes)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: RevokeOAuthRefreshToken :execresult
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1 AND client_id = $2;
+
+-- name: RevokeOAuthRefreshTokensForClient :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL;
+
+-- name: RotateOAuthRefreshToken :one
+-- Revokes a valid refresh token and returns its grant, so that only one of
+-- several concurrent uses of the token succeeds.
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND client_id = $2 AND expires_at > NOW() AND revoked_at IS NULL
+RETURNING user_id, scopes;
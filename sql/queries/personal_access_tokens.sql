@@ -0,0 +1,24 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, NOW(), $5
+)
+RETURNING *;
+
+-- name: GetValidPersonalAccessToken :one
+SELECT * FROM personal_access_tokens
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ListPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at DESC;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens SET last_used_at = NOW() WHERE id = $1;
+
+-- name: RevokePersonalAccessToken :execresult
+UPDATE personal_access_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokePersonalAccessTokensForUser :exec
+UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL;
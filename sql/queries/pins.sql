@@ -0,0 +1,7 @@
+-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE SET chirp_id = EXCLUDED.chirp_id, created_at = NOW();
+
+-- name: UnpinChirp :execresult
+DELETE FROM pinned_chirps WHERE user_id = $1 AND chirp_id = $2;
@@ -0,0 +1,39 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at, created_at)
+VALUES ($1, $2, NOW());
+
+-- name: CreatePollOptions :exec
+INSERT INTO poll_options (chirp_id, position, label)
+SELECT sqlc.arg(chirp_id), (t.ord - 1)::int, t.label
+FROM unnest(sqlc.arg(labels)::text[]) WITH ORDINALITY AS t(label, ord);
+
+-- name: GetPoll :one
+SELECT * FROM polls WHERE chirp_id = $1;
+
+-- name: CastPollVote :execresult
+-- Records a vote while the poll is open. A second vote from the same user
+-- fails on the primary key.
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+SELECT p.chirp_id, sqlc.arg(user_id), sqlc.arg(position), NOW()
+FROM polls p
+WHERE p.chirp_id = sqlc.arg(chirp_id) AND p.closed_at IS NULL AND p.closes_at > NOW();
+
+-- name: ListPollsForChirps :many
+SELECT * FROM polls WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListPollOptionsForChirps :many
+SELECT o.chirp_id, o.position, o.label, COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.chirp_id = o.chirp_id AND v.position = o.position
+WHERE o.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY o.chirp_id, o.position, o.label
+ORDER BY o.chirp_id, o.position;
+
+-- name: ListPollVotesForUser :many
+SELECT chirp_id, position FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: CloseDuePolls :many
+UPDATE polls SET closed_at = NOW()
+WHERE closed_at IS NULL AND closes_at <= NOW()
+RETURNING *;
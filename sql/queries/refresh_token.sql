@@ -7,7 +7,7 @@ RETURNING *;
 
 
 -- name: GetValidRefreshToken :one 
-SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token = $1 AND expires_at > NOW() AND revoked_at IS NULL);
+SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token = $1 AND expires_at > NOW() AND revoked_at IS NULL AND client_id IS NULL);
 
 -- name: RevokeRefreshToken :execresult
 UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1;
@@ -18,4 +18,12 @@ FROM users u
 INNER JOIN refresh_tokens rt ON u.id = rt.user_id
 WHERE rt.token = $1
   AND rt.expires_at > NOW()      
-  AND rt.revoked_at IS NULL;    
+  AND rt.revoked_at IS NULL
+  AND rt.client_id IS NULL;
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: ListSessionsForUser :many
+SELECT created_at, updated_at, expires_at, revoked_at, client_id, scopes
+FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at DESC;
@@ -0,0 +1,35 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, reporter_id, target_type, target_id, reported_user_id, reason, details, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, $5, $6, NOW(), NOW()
+)
+ON CONFLICT (reporter_id, target_type, target_id) WHERE status IN ('open', 'claimed') DO NOTHING
+RETURNING *;
+
+-- name: GetReportById :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: ListReports :many
+SELECT * FROM reports
+WHERE (sqlc.arg('status')::text = '' OR status = sqlc.arg('status')::text)
+  AND (sqlc.arg('target_type')::text = '' OR target_type = sqlc.arg('target_type')::text)
+ORDER BY created_at ASC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ClaimReport :one
+UPDATE reports SET status = 'claimed', claimed_by = $2, claimed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'open'
+RETURNING *;
+
+-- name: CloseReport :one
+UPDATE reports
+SET status = sqlc.arg('status'), resolution = sqlc.narg('resolution'), resolution_note = sqlc.arg('resolution_note'),
+    resolved_by = sqlc.arg('resolved_by'), resolved_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg('id')
+  AND (status = 'open' OR (status = 'claimed' AND claimed_by = sqlc.arg('resolved_by')))
+RETURNING *;
+
+-- name: ResolvePendingReportsForTarget :exec
+UPDATE reports
+SET status = 'resolved', resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW(), updated_at = NOW()
+WHERE target_type = $1 AND target_id = $2 AND status IN ('open', 'claimed');
@@ -0,0 +1,37 @@
+-- name: GetSubscriptionForUpdate :one
+SELECT * FROM subscriptions WHERE user_id = $1 FOR UPDATE;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, status, current_period_end, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status, current_period_end = EXCLUDED.current_period_end, updated_at = NOW()
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (user_id, event_type, old_status, new_status, current_period_end, webhook_event_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW());
+
+-- name: ListSubscriptionEvents :many
+SELECT * FROM subscription_events WHERE user_id = $1 ORDER BY created_at DESC, id DESC;
+
+-- name: IsChirpyRed :one
+SELECT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE user_id = $1 AND status IN ('active', 'past_due')
+      AND (current_period_end IS NULL OR current_period_end > NOW())
+);
+
+-- name: ListLapsedSubscriptions :many
+SELECT * FROM subscriptions
+WHERE status IN ('active', 'past_due') AND current_period_end <= NOW()
+ORDER BY current_period_end
+LIMIT 500;
+
+-- name: ListChirpyRedUsers :many
+SELECT user_id FROM subscriptions
+WHERE user_id = ANY(sqlc.arg('user_ids')::uuid[]) AND status IN ('active', 'past_due')
+  AND (current_period_end IS NULL OR current_period_end > NOW());
@@ -0,0 +1,18 @@
+-- name: CreateUserToken :exec
+INSERT INTO user_tokens (token_hash, user_id, purpose, email, created_at, expires_at)
+VALUES (
+    $1, $2, $3, $4, NOW(), $5
+);
+
+-- name: ConsumeUserToken :one
+-- Only consumes a token sent to the user's current address.
+UPDATE user_tokens SET used_at = NOW()
+WHERE token_hash = $1
+  AND purpose = $2
+  AND used_at IS NULL
+  AND expires_at > NOW()
+  AND email = (SELECT email FROM users WHERE users.id = user_tokens.user_id)
+RETURNING user_id, email;
+
+-- name: DeleteUnusedUserTokens :exec
+DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
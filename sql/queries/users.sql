@@ -9,13 +9,84 @@ RETURNING *;
 TRUNCATE TABLE users CASCADE;
 
 -- name: GetUserByEmail :one 
-SELECT id, created_at, is_chirpy_red ,updated_at, email, password FROM users WHERE email = $1;
+SELECT id, created_at, EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = users.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red, updated_at, email, password, failed_login_attempts, locked_until, email_verified, role, suspended_at, suspended_reason FROM users WHERE email = $1;
 
 
 -- name: UpdateUserById :one
-UPDATE users SET email = $1, password = $2 WHERE id = $3 RETURNING id, created_at, updated_at, email, is_chirpy_red;
+-- Changing the address drops its verification.
+UPDATE users SET email = $1, password = $2, email_verified = email_verified AND email = $1, updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = users.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red;
 
--- name: UpgradeToRed :execresult
+-- name: UpdateUserPassword :exec
+UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2;
 
-UPDATE users SET is_chirpy_red = TRUE WHERE id = $1; 
+-- name: RecordFailedLogin :one
+UPDATE users SET failed_login_attempts = failed_login_attempts + 1 WHERE id = $1 RETURNING failed_login_attempts;
 
+-- name: LockUserUntil :exec
+UPDATE users SET locked_until = $1 WHERE id = $2;
+
+-- name: ResetFailedLogins :exec
+UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1;
+
+-- name: UnlockUser :execresult
+UPDATE users SET failed_login_attempts = 0, locked_until = NULL, updated_at = NOW() WHERE id = $1;
+
+-- name: MarkEmailVerified :exec
+UPDATE users SET email_verified = TRUE, updated_at = NOW() WHERE id = $1 AND email = $2;
+
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserAuthState :one
+SELECT role, suspended_at, EXISTS (SELECT 1 FROM account_deletions WHERE user_id = users.id) AS deletion_pending
+FROM users WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT id, created_at, updated_at, email, EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = users.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red, email_verified, role, suspended_at, suspended_reason
+FROM users
+WHERE (sqlc.arg('query')::text = '' OR email ILIKE '%' || sqlc.arg('query')::text || '%')
+  AND (sqlc.arg('role')::text = '' OR role = sqlc.arg('role')::text)
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SetUserRole :execresult
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2;
+
+-- name: SuspendUser :execresult
+UPDATE users SET suspended_at = NOW(), suspended_reason = $1, updated_at = NOW() WHERE id = $2;
+
+-- name: UnsuspendUser :execresult
+UPDATE users SET suspended_at = NULL, suspended_reason = NULL, updated_at = NOW() WHERE id = $1;
+
+-- name: GetPublicProfile :one
+SELECT u.id, u.created_at, u.handle, u.display_name, u.bio, u.location, u.website, u.avatar_url,
+    EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = u.id AND s.status IN ('active', 'past_due') AND (s.current_period_end IS NULL OR s.current_period_end > NOW())) AS is_chirpy_red,
+    (SELECT COUNT(*) FROM follows WHERE followee_id = u.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = u.id) AS following_count,
+    (SELECT COUNT(*) FROM chirps WHERE user_id = u.id AND hidden_at IS NULL AND status = 'published') AS chirp_count
+FROM users u
+WHERE u.id = sqlc.narg('id') OR lower(u.handle) = lower(sqlc.narg('handle'));
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = COALESCE(sqlc.narg('handle'), handle),
+    display_name = COALESCE(sqlc.narg('display_name'), display_name),
+    bio = COALESCE(sqlc.narg('bio'), bio),
+    location = COALESCE(sqlc.narg('location'), location),
+    website = COALESCE(sqlc.narg('website'), website),
+    avatar_url = COALESCE(sqlc.narg('avatar_url'), avatar_url),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: UpdateUserEmail :one
+UPDATE users SET email = $1, email_verified = FALSE, updated_at = NOW() WHERE id = $2
+RETURNING *;
+
+-- name: DeleteUserById :execresult
+DELETE FROM users WHERE id = $1;
+
+-- name: ListUserIdsByHandles :many
+SELECT id FROM users WHERE lower(handle) = ANY(sqlc.arg('handles')::text[]);
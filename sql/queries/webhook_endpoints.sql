@@ -0,0 +1,62 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, owner_id, url, secret, events, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id IS NOT DISTINCT FROM sqlc.narg('owner_id')
+ORDER BY created_at DESC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints WHERE id = $1;
+
+-- name: EnqueueWebhookDeliveries :exec
+-- Queues event for every endpoint subscribed to it: admin endpoints and the
+-- endpoints of the user the event is about.
+INSERT INTO webhook_deliveries (id, endpoint_id, event_type, payload, status, next_attempt_at, created_at)
+SELECT gen_random_uuid(), id, sqlc.arg('event_type'), sqlc.arg('payload'), 'pending', NOW(), NOW()
+FROM webhook_endpoints
+WHERE sqlc.arg('event_type')::text = ANY(events)
+  AND (owner_id IS NULL OR owner_id = sqlc.narg('subject_id'));
+
+-- name: ClaimWebhookDeliveries :many
+-- Takes due deliveries and pushes their next attempt back, so a worker that
+-- dies mid-delivery only delays them. SKIP LOCKED lets several workers claim
+-- batches side by side.
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = NOW() + INTERVAL '5 minutes'
+FROM webhook_endpoints e
+WHERE e.id = d.endpoint_id AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.event_type, d.payload, d.attempts, e.url, e.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = $2, last_error = '', delivered_at = NOW()
+WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+WHERE id = $1 AND endpoint_id = $2
+RETURNING *;
@@ -0,0 +1,20 @@
+-- name: RecordWebhookEvent :one
+-- Stores a newly delivered event. A redelivery only returns a row when the
+-- earlier attempt failed or was abandoned mid-way, so it can be processed
+-- again.
+INSERT INTO webhook_events (source, id, event_type, payload, status, attempts, received_at)
+VALUES ($1, $2, $3, $4, 'received', 1, NOW())
+ON CONFLICT (source, id) DO UPDATE
+SET attempts = webhook_events.attempts + 1, payload = EXCLUDED.payload, status = 'received', error = ''
+WHERE webhook_events.status = 'failed'
+   OR (webhook_events.status = 'received' AND webhook_events.received_at < NOW() - INTERVAL '5 minutes')
+RETURNING *;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events SET status = $3, error = $4, processed_at = NOW()
+WHERE source = $1 AND id = $2;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+ORDER BY received_at DESC
+LIMIT $1 OFFSET $2;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
+-- Accounts created before verification existed keep posting as before.
+UPDATE users SET email_verified = true;
+
+CREATE TABLE user_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE user_tokens;
+ALTER TABLE users DROP COLUMN email_verified;
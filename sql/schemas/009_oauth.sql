@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+    id TEXT PRIMARY KEY,
+    secret_hash TEXT,
+    name TEXT NOT NULL,
+    redirect_uris TEXT[] NOT NULL,
+    owner_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE oauth_authorization_codes (
+    code_hash TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE TABLE oauth_consents (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, client_id)
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN client_id TEXT REFERENCES oauth_clients (id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD COLUMN scopes TEXT[];
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN scopes;
+ALTER TABLE refresh_tokens DROP COLUMN client_id;
+DROP TABLE oauth_consents;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'));
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN suspended_reason TEXT;
+
+CREATE TABLE admin_actions (
+    id UUID PRIMARY KEY,
+    actor_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE admin_actions;
+ALTER TABLE users DROP COLUMN suspended_reason;
+ALTER TABLE users DROP COLUMN suspended_at;
+ALTER TABLE users DROP COLUMN role;
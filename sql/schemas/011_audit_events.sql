@@ -0,0 +1,41 @@
+-- +goose Up
+-- details is JSON rather than JSONB so the stored text is exactly what was
+-- hashed. actor_id has no foreign key: audit rows must outlive their users.
+CREATE TABLE audit_events (
+    seq BIGSERIAL PRIMARY KEY,
+    id UUID NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL,
+    actor_id UUID,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    details JSON NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_events_action_idx ON audit_events (action, created_at);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor_id, created_at);
+CREATE INDEX audit_events_target_idx ON audit_events (target_type, target_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_no_update_delete
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TABLE audit_events;
+DROP FUNCTION audit_events_append_only();
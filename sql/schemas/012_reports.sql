@@ -0,0 +1,29 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    reporter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_type TEXT NOT NULL CHECK (target_type IN ('chirp', 'user')),
+    target_id UUID NOT NULL,
+    reported_user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'impersonation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+    claimed_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolved_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    resolution TEXT CHECK (resolution IN ('hide_chirp', 'delete_chirp', 'suspend_user')),
+    resolution_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A reporter can only have one pending report per target.
+CREATE UNIQUE INDEX reports_pending_unique ON reports (reporter_id, target_type, target_id) WHERE status IN ('open', 'claimed');
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+
+-- +goose Down
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
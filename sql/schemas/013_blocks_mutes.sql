@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+CREATE TABLE mutes (
+    muter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+-- +goose Down
+DROP TABLE mutes;
+DROP TABLE blocks;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle TEXT;
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN location TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN website TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (lower(handle));
+
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+
+-- +goose Down
+DROP TABLE follows;
+DROP INDEX users_handle_lower_idx;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN website;
+ALTER TABLE users DROP COLUMN location;
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN display_name;
+ALTER TABLE users DROP COLUMN handle;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Every table that references users or chirps cascades on delete, except the
+-- moderation and admin records that keep a SET NULL actor and the audit log,
+-- which has no foreign keys so purging a user never rewrites it.
+CREATE TABLE account_deletions (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    requested_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    purge_after TIMESTAMP NOT NULL
+);
+CREATE INDEX account_deletions_purge_after_idx ON account_deletions (purge_after);
+
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    file_path TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+CREATE UNIQUE INDEX data_exports_one_pending ON data_exports (user_id) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE data_exports;
+DROP TABLE account_deletions;
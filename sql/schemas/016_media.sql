@@ -0,0 +1,24 @@
+-- +goose Up
+-- Attachments are uploaded before the chirp exists, so chirp_id starts out
+-- NULL. Deleting a chirp only detaches its media; the maintenance job removes
+-- detached rows together with their blobs.
+CREATE TABLE media_attachments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    thumbnail_type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX media_attachments_chirp_id_idx ON media_attachments (chirp_id, position);
+CREATE INDEX media_attachments_detached_idx ON media_attachments (created_at) WHERE chirp_id IS NULL;
+CREATE INDEX media_attachments_user_id_idx ON media_attachments (user_id);
+
+-- +goose Down
+DROP TABLE media_attachments;
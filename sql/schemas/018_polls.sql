@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE polls (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps (id) ON DELETE CASCADE,
+    closes_at TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX polls_open_idx ON polls (closes_at) WHERE closed_at IS NULL;
+
+CREATE TABLE poll_options (
+    chirp_id UUID NOT NULL REFERENCES polls (chirp_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    PRIMARY KEY (chirp_id, position)
+);
+
+-- The primary key is what limits each user to one vote per poll.
+CREATE TABLE poll_votes (
+    chirp_id UUID NOT NULL REFERENCES polls (chirp_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chirp_id, user_id),
+    FOREIGN KEY (chirp_id, position) REFERENCES poll_options (chirp_id, position) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- One row per delivered event, keyed by the provider's event ID so
+-- redeliveries are recognised. payload keeps the raw body for debugging.
+CREATE TABLE webhook_events (
+    source TEXT NOT NULL,
+    id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+    error TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 1,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP,
+    PRIMARY KEY (source, id)
+);
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
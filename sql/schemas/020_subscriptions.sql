@@ -0,0 +1,39 @@
+-- +goose Up
+-- Chirpy Red is derived from the subscription: a user is a member while the
+-- status is active or past_due and current_period_end hasn't passed.
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'refunded', 'expired')),
+    current_period_end TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX subscriptions_lapsing_idx ON subscriptions (current_period_end) WHERE status IN ('active', 'past_due');
+
+CREATE TABLE subscription_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    old_status TEXT NOT NULL,
+    new_status TEXT NOT NULL,
+    current_period_end TIMESTAMP,
+    webhook_event_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX subscription_events_user_id_idx ON subscription_events (user_id, created_at);
+
+-- Existing members keep Chirpy Red with no end date.
+INSERT INTO subscriptions (user_id, status) SELECT id, 'active' FROM users WHERE is_chirpy_red;
+INSERT INTO subscription_events (user_id, event_type, old_status, new_status)
+SELECT id, 'migrated', '', 'active' FROM users WHERE is_chirpy_red;
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN is_chirpy_red BOOLEAN DEFAULT FALSE;
+UPDATE users SET is_chirpy_red = TRUE
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status IN ('active', 'past_due') AND (current_period_end IS NULL OR current_period_end > NOW())
+);
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Chirp length is limited per tier by the entitlements config, not by the
+-- column.
+ALTER TABLE chirps
+    ALTER COLUMN body TYPE TEXT,
+    ADD COLUMN edited_at TIMESTAMP;
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+ALTER TABLE chirps
+    DROP COLUMN edited_at,
+    ALTER COLUMN body TYPE VARCHAR(141) USING LEFT(body, 141);
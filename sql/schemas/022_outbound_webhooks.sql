@@ -0,0 +1,35 @@
+-- +goose Up
+-- Endpoints registered by integrators. owner_id is NULL for endpoints
+-- registered by admins, which receive every event; a user's endpoint only
+-- receives events about that user.
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY,
+    owner_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX webhook_endpoints_owner_id_idx ON webhook_endpoints (owner_id);
+
+-- The delivery queue. Pending rows are picked up once next_attempt_at has
+-- passed; rows that fail MaxAttempts times end up dead until replayed.
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
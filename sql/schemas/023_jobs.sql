@@ -0,0 +1,22 @@
+-- +goose Up
+-- Background jobs. Finished jobs are deleted; jobs that ran out of attempts
+-- stay as dead for inspection. A running job whose locked_until has passed
+-- lost its worker and is handed out again.
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'running', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    unique_key TEXT,
+    last_error TEXT NOT NULL DEFAULT '',
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status IN ('pending', 'running');
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key) WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE jobs;
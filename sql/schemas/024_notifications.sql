@@ -0,0 +1,29 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN reply_to_id UUID REFERENCES chirps (id) ON DELETE SET NULL;
+CREATE INDEX chirps_reply_to_id_idx ON chirps (reply_to_id);
+
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    actor_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP
+);
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+-- Only opt-outs need a row; every type is enabled by default.
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
+DROP INDEX chirps_reply_to_id_idx;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- The live chirp stream. Every instance LISTENs on chirp_events and relays
+-- the rows other instances insert to its own clients; seq doubles as the
+-- SSE event id, so reconnecting clients can resume. chirp_id and user_id
+-- have no foreign keys: deletions are events too.
+CREATE TABLE chirp_events (
+    seq BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    hashtags TEXT[] NOT NULL DEFAULT '{}',
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_events_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('chirp_events', NEW.seq::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_events_notify
+AFTER INSERT ON chirp_events
+FOR EACH ROW EXECUTE FUNCTION chirp_events_notify();
+
+-- +goose Down
+DROP TABLE chirp_events;
+DROP FUNCTION chirp_events_notify();
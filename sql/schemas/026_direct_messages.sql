@@ -0,0 +1,58 @@
+-- +goose Up
+-- Conversations are one-to-one or small groups. direct_key is set on
+-- one-to-one conversations so a pair of users only ever has one.
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    is_group BOOLEAN NOT NULL,
+    direct_key TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE conversation_members (
+    conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_read_at TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX messages_conversation_id_idx ON messages (conversation_id, created_at);
+
+-- Who may start a conversation with a user. Users without a row accept
+-- messages from everyone.
+CREATE TABLE messaging_settings (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    dm_policy TEXT NOT NULL CHECK (dm_policy IN ('everyone', 'following', 'nobody'))
+);
+
+-- Every instance LISTENs on messages to push new messages to the streams
+-- of the members connected to it.
+-- +goose StatementBegin
+CREATE FUNCTION messages_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('messages', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER messages_notify
+AFTER INSERT ON messages
+FOR EACH ROW EXECUTE FUNCTION messages_notify();
+
+-- +goose Down
+DROP TABLE messaging_settings;
+DROP TABLE messages;
+DROP FUNCTION messages_notify();
+DROP TABLE conversation_members;
+DROP TABLE conversations;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX bookmarks_user_id_idx ON bookmarks (user_id, created_at);
+
+-- Lists are named groups of accounts curated by their owner. Private lists
+-- are only visible to the owner; members are not told they were added.
+CREATE TABLE lists (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    is_private BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX lists_owner_id_idx ON lists (owner_id, created_at);
+
+CREATE TABLE list_members (
+    list_id UUID NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (list_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
+DROP TABLE bookmarks;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Each user can pin one of their own chirps to the top of their profile.
+CREATE TABLE pinned_chirps (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL UNIQUE REFERENCES chirps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE likes (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX likes_user_id_idx ON likes (user_id, created_at);
+
+-- +goose Down
+DROP TABLE likes;
+DROP TABLE pinned_chirps;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Tokens are bound to the address they were sent to, so a token sent to a
+-- previous address can't verify a new one. Tokens issued before this have
+-- no address and are no longer accepted.
+ALTER TABLE user_tokens ADD COLUMN email TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE user_tokens DROP COLUMN email;